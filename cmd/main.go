@@ -89,7 +89,16 @@ func main() {
 	//==========================================================================
 	tickerCtx, tickerCancel := context.WithCancel(context.Background())
 	defer tickerCancel()
-	go updateCoinQuotes(tickerCtx, app, logger, services)
+	// One ticker loop per polling tier, each on its own interval
+	for _, tier := range app.Interval.Tiers {
+		go updateCoinQuotes(tickerCtx, tier, app, logger, services)
+	}
+	// Global market metrics on their own interval
+	go updateGlobalMetrics(tickerCtx, app, logger, services)
+	// Gap repair runs at startup (fills downtime) and on its own interval
+	if database != nil {
+		go repairQuoteGaps(tickerCtx, app, logger, services)
+	}
 
 	//==========================================================================
 	// Application Shutdown (blocks main() thread until shutdown)
@@ -143,30 +152,46 @@ func InitDatabase(app *config.AppConfig, logger *slog.Logger) (*db.Database, err
 	return database, nil
 }
 
-// updateCoinQuotes orchestrates calls to the API and DB updates with new data on set time interval.
+// updateCoinQuotes orchestrates calls to the API and DB updates with new data for the coins of one tier on the tier interval.
 // Uses two contexts:
 // 1. ctx from main thread called by tickerCancel() when app shuts down.
-// 2. reqCtx with timeout for each API call.
-func updateCoinQuotes(ctx context.Context, app *config.AppConfig, logger *slog.Logger, services *Services) {
-	timeInterval := app.Interval.TickerInterval
-	ticker := time.NewTicker(timeInterval) // returns a *time.Ticker channel that reads from the channel C at set interval
-	defer ticker.Stop()
+// 2. reqCtx with the sync timeout (defaults to the tier interval) for each sync.
+func updateCoinQuotes(ctx context.Context, tier config.TierSettings, app *config.AppConfig, logger *slog.Logger, services *Services) {
+	logger = logger.With("tier", tier.Name)
+	timeInterval := tier.Interval
+	syncTimeout := app.Interval.SyncTimeout
+	if syncTimeout <= 0 {
+		syncTimeout = timeInterval
+	}
+	intervalTicker := time.NewTicker(timeInterval) // returns a *time.Ticker channel that reads from the channel C at set interval
+	defer intervalTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done(): // read from main thread context when tickerCancel() is called
 			logger.Info("tickerContext cancelled from main thread, shutting down ticker service")
 			return // graceful shutdown, function exits
-		case <-ticker.C: // read from ticker.C channel at set interval
-			// Create a new request context for each API call with timeout
-			reqCtx, reqCancel := context.WithTimeout(ctx, app.CMC.RequestTimeout)
-			err := services.Ticker.Sync(reqCtx)
+		case <-intervalTicker.C: // read from intervalTicker.C channel at set interval
+			// Create a new request context for each sync with timeout
+			reqCtx, reqCancel := context.WithTimeout(ctx, syncTimeout)
+			report, err := services.Ticker.SyncTier(reqCtx, tier.Name)
 			if err != nil {
-				logger.Error("failed to fetch and decode data", "error", err)
+				logger.Error("failed to fetch and decode data", "error", err, "report", report)
 				reqCancel() // release resources if API call fails
 				continue
 			}
-			logger.Info("data synced from CMC API")
+			// Surface coins the provider did not return for logging and alerting
+			if missing := report.MissingCoins(); len(missing) > 0 {
+				logger.Warn("coins missing from provider response", "cmc_ids", missing)
+			}
+			// Surface coins the provider has stopped updating
+			if stale := report.StaleCoins(); len(stale) > 0 {
+				logger.Warn("coins with stale quotes", "cmc_ids", stale)
+			}
+			for _, chunkErr := range report.ChunkErrors {
+				logger.Warn("provider call failed", "error", chunkErr)
+			}
+			logger.Info("data synced from provider", "report", report)
 			reqCancel() // release resources if API call succeeds
 
 		}
@@ -174,10 +199,82 @@ func updateCoinQuotes(ctx context.Context, app *config.AppConfig, logger *slog.L
 	}
 }
 
+// updateGlobalMetrics syncs the global market metrics every global metrics interval (0 disables).
+// Each call uses a request context with timeout like updateCoinQuotes.
+func updateGlobalMetrics(ctx context.Context, app *config.AppConfig, logger *slog.Logger, services *Services) {
+	logger = logger.With("job", "global_metrics")
+	if app.Interval.GlobalMetricsInterval <= 0 {
+		logger.Info("Global metrics sync disabled - no global metrics interval")
+		return
+	}
+	intervalTicker := time.NewTicker(app.Interval.GlobalMetricsInterval)
+	defer intervalTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("tickerContext cancelled from main thread, shutting down global metrics sync")
+			return
+		case <-intervalTicker.C:
+			reqCtx, reqCancel := context.WithTimeout(ctx, app.CMC.RequestTimeout)
+			metrics, err := services.Ticker.SyncGlobalMetrics(reqCtx)
+			reqCancel()
+			if err != nil {
+				logger.Error("failed to sync global metrics", "error", err)
+				continue
+			}
+			logger.Info("global metrics synced from CMC API", "btc_dominance", metrics.BtcDominance,
+				"eth_dominance", metrics.EthDominance, "last_updated", metrics.LastUpdated)
+		}
+	}
+}
+
+// repairQuoteGaps backfills gaps in the recent quote history once at startup and then every gap scan interval (0 disables).
+// Each run gets the scan interval as timeout so a slow repair never overlaps the next one.
+func repairQuoteGaps(ctx context.Context, app *config.AppConfig, logger *slog.Logger, services *Services) {
+	logger = logger.With("job", "gap_repair")
+	if app.Interval.GapScanInterval <= 0 {
+		logger.Info("Gap repair disabled - no gap scan interval")
+		return
+	}
+	intervalTicker := time.NewTicker(app.Interval.GapScanInterval)
+	defer intervalTicker.Stop()
+
+	for {
+		runCtx, runCancel := context.WithTimeout(ctx, app.Interval.GapScanInterval)
+		report, err := services.Ticker.RepairGaps(runCtx)
+		runCancel()
+		if err != nil {
+			logger.Error("failed to repair quote history gaps", "error", err)
+		} else {
+			for _, gap := range report.Unfilled {
+				logger.Warn("quote history gap not filled", "cmc_id", gap.CmcID, "currency", gap.Currency,
+					"gap_start", gap.Start, "gap_end", gap.End, "error", gap.Err)
+			}
+			logger.Info("quote history gaps repaired", "detected", report.Detected, "filled", len(report.Filled),
+				"unfilled", len(report.Unfilled), "credit_count", report.CreditCount)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("tickerContext cancelled from main thread, shutting down gap repair")
+			return
+		case <-intervalTicker.C:
+		}
+	}
+}
+
 // TEMP HELPERS ONLY. REMOVE BEFORE PRODUCTION.
 func PrintSettings(app *config.AppConfig) {
 	fmt.Printf("App in production: %v\n", app.AppCfg.InProduciton)
 	fmt.Printf("Use DB: %v\n", app.AppCfg.UseDB)
+	fmt.Printf("Quote provider: %v\n", app.Provider.Name)
 	fmt.Printf("Base URL: %v\n", app.CMC.BaseURL)
 	fmt.Printf("Request Timeout: %v\n", app.CMC.RequestTimeout)
+	for _, tier := range app.Interval.Tiers {
+		fmt.Printf("Ticker tier %s: %v\n", tier.Name, tier.Interval)
+	}
+	fmt.Printf("Sync timeout: %v\n", app.Interval.SyncTimeout)
+	fmt.Printf("Gap scan interval: %v\n", app.Interval.GapScanInterval)
+	fmt.Printf("Global metrics interval: %v\n", app.Interval.GlobalMetricsInterval)
 }
@@ -3,16 +3,24 @@ package config
 import (
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // AppConfig holds all configuration settings for the application
 type AppConfig struct {
-	DB       DBSettings
-	CMC      CMCSettings
-	AppCfg   AppSettings
-	Srv      *http.Server
-	Interval IntervalSettings
+	DB        DBSettings
+	Provider  ProviderSettings
+	CMC       CMCSettings
+	CoinGecko CoinGeckoSettings
+	Binance   BinanceSettings
+	Coinbase  CoinbaseSettings
+	Kraken    KrakenSettings
+	AppCfg    AppSettings
+	Srv       *http.Server
+	Interval  IntervalSettings
+	Validate  ValidationSettings
 }
 
 // AppCofig holds general application settings
@@ -30,24 +38,123 @@ type DBSettings struct {
 	DBName   string
 }
 
+// ProviderSettings selects the upstream quote provider used by the ticker sync. Each provider is configured in its
+// own section (ex. CMC for "cmc").
+type ProviderSettings struct {
+	Name string
+}
+
 // CMCCOnfig holds Coinmarketcap API configuration
 type CMCSettings struct {
 	APIKey         string
 	BaseURL        string
 	QuotesURL      string
 	IDMapURL       string
+	ListingsURL    string
+	HistoricalURL  string
+	GlobalURL      string // global metrics endpoint
 	RequestTimeout time.Duration
+	// Sync mode: "quotes" fetches the tracked coin ID's, "listings" fetches the top coins from the listings endpoint
+	SyncMode      string
+	ListingsStart int    // 1 based rank offset
+	ListingsLimit int    // number of coins
+	ListingsSort  string // market_cap, volume_24h, percent_change_24h, etc.
+	// Quote conversion targets. CMC does not allow convert and convert_id in the same call.
+	Convert           []string // currency symbols (ex. USD, EUR, BTC), USD if neither Convert nor ConvertIDs is set
+	ConvertIDs        []string // CMC convert_id values (ex. 2781 for USD)
+	MaxConvertPerCall int      // max convert options per call allowed by the CMC plan
+	Aux               []string // aux fields requested from the quotes endpoint
+	// Quote fetching. Tracked ID's are split into chunks fetched concurrently.
+	IDChunkSize    int // max ID's per call
+	MaxConcurrency int // max concurrent calls per sync
+	// Credit budget. On-demand refreshes are refused if they would exceed CreditBudget credits per CreditWindow.
+	CreditBudget int // 0 disables the budget
+	CreditWindow time.Duration
+	// Historical backfill. Each backfill (over all of its runs) and each gap repair pass stops before spending more
+	// than BackfillCreditCap credits.
+	BackfillCreditCap int // 0 disables the cap
+	BackfillPageSize  int // data points per historical call (max 10000)
+}
+
+// CoinGeckoSettings holds CoinGecko API configuration (TICKER_PROVIDER=coingecko)
+type CoinGeckoSettings struct {
+	APIKey       string
+	APIKeyHeader string // x-cg-demo-api-key (demo plan) or x-cg-pro-api-key (paid plans)
+	BaseURL      string
+	Endpoint     string         // "markets" (/coins/markets) or "simple" (/simple/price)
+	VsCurrencies []string       // quote currencies (ex. usd, eur)
+	IDs          map[int]string // CMC ID -> CoinGecko ID (ex. 1:bitcoin)
+	RateLimit    int            // max calls per minute, 0 disables the limit
+}
+
+// BinanceSettings holds Binance spot API configuration (TICKER_PROVIDER=binance)
+type BinanceSettings struct {
+	BaseURL     string
+	Endpoint    string         // "24hr" (/api/v3/ticker/24hr) or "price" (/api/v3/ticker/price)
+	Symbols     map[int]string // CMC ID -> Binance base asset (ex. 1:BTC)
+	QuoteAssets []string       // quote assets paired with every base asset (ex. USDT, USDC)
+	RateLimit   int            // max calls per minute, 0 disables the limit
+}
+
+// CoinbaseSettings holds Coinbase Exchange API configuration (TICKER_PROVIDER=coinbase)
+type CoinbaseSettings struct {
+	BaseURL         string
+	Currencies      map[int]string // CMC ID -> Coinbase base currency (ex. 1:BTC)
+	QuoteCurrencies []string       // quote currencies paired with every base currency into products (ex. USD -> BTC-USD)
+	RateLimit       int            // max calls per second, 0 disables the limit
+}
+
+// KrakenSettings holds Kraken spot API configuration (TICKER_PROVIDER=kraken)
+type KrakenSettings struct {
+	BaseURL         string
+	Assets          map[int]string // CMC ID -> asset, common or Kraken code (ex. 1:BTC or 1:XBT)
+	QuoteCurrencies []string       // quote currencies paired with every asset (ex. USD -> XBTUSD)
+	RateLimit       int            // max calls per minute, 0 disables the limit
 }
 
 // IntervalSettings holds the time settings in seconds for the ticker and mapper services
 type IntervalSettings struct {
 	TickerInterval time.Duration
 	MapperInterval time.Duration
+	StaleAfter     time.Duration // flag coins whose upstream last_updated is older than this
+	// Polling tiers (ex. hot:30s,normal:2m,cold:15m). Each tracked coin belongs to exactly one tier (tracked_coins.tier).
+	// Coins with an unknown tier are polled with DefaultTier. Defaults to a single tier polled every TickerInterval.
+	Tiers       []TierSettings
+	DefaultTier string
+	// SyncTimeout bounds one tier sync (all chunks, retries and the DB write). 0 uses the tier interval so a slow sync
+	// never overlaps the next tick.
+	SyncTimeout time.Duration
+	// Gap repair scans the last GapLookback of history every GapScanInterval (and at startup) for gaps longer than
+	// GapTolerance times the tier interval and backfills them.
+	GapScanInterval time.Duration
+	GapLookback     time.Duration
+	GapTolerance    float64
+	// Global market metrics are synced every GlobalMetricsInterval (0 disables)
+	GlobalMetricsInterval time.Duration
+}
+
+// TierSettings holds the polling interval of a group of coins
+type TierSettings struct {
+	Name     string
+	Interval time.Duration
+}
+
+// ValidationSettings holds the sanity checks applied to upstream quotes before they are written to the database.
+// Percent thresholds of 0 disable the check.
+type ValidationSettings struct {
+	RejectNonPositivePrice bool    // price <= 0
+	RejectNonFinite        bool    // NaN or Inf in any numeric field
+	MaxPriceMovePct        float64 // max percent move versus the last stored price
+	// A move above MaxPriceMovePct is accepted once this many consecutive quarantined quotes agree with the new price
+	// (within MaxPriceMovePct), so a real large move is not quarantined forever. Repeats of the same quarantined
+	// price count once. 0 never accepts the move.
+	PriceMoveConfirmations int
+	MarketCapTolerancePct  float64 // max percent difference between market cap and price * circulating supply
 }
 
 // NewConfig creates and returns a new AppConfig instance
 func NewAppConfig() *AppConfig {
-	return &AppConfig{
+	app := &AppConfig{
 		DB: DBSettings{
 			Host:     getEnv("DB_HOST", "postgres"),
 			Port:     getEnv("DB_PORT", "5432"),
@@ -55,12 +162,64 @@ func NewAppConfig() *AppConfig {
 			Password: getEnv("DB_PASSWORD", "postgres"),
 			DBName:   getEnv("DB_NAME", "postgres"),
 		},
+		Provider: ProviderSettings{
+			Name: getEnv("TICKER_PROVIDER", "cmc"),
+		},
 		CMC: CMCSettings{
-			APIKey:         getEnv("CMC_API_KEY", "123"),
-			BaseURL:        getEnv("CMC_BASE_URL", ""),
-			QuotesURL:      getEnv("CMC_QUOTES_URL", ""),
-			IDMapURL:       getEnv("CMC_ID_MAP_URL", ""),
-			RequestTimeout: getEnvAsDuration("CMC_REQUEST_TIMEOUT", "30s"),
+			APIKey:            getEnv("CMC_API_KEY", "123"),
+			BaseURL:           getEnv("CMC_BASE_URL", ""),
+			QuotesURL:         getEnv("CMC_QUOTES_URL", ""),
+			IDMapURL:          getEnv("CMC_ID_MAP_URL", ""),
+			ListingsURL:       getEnv("CMC_LISTINGS_URL", ""),
+			HistoricalURL:     getEnv("CMC_HISTORICAL_URL", ""),
+			GlobalURL:         getEnv("CMC_GLOBAL_METRICS_URL", ""),
+			RequestTimeout:    getEnvAsDuration("CMC_REQUEST_TIMEOUT", "30s"),
+			SyncMode:          getEnv("CMC_SYNC_MODE", "quotes"),
+			ListingsStart:     getEnvAsInt("CMC_LISTINGS_START", 1),
+			ListingsLimit:     getEnvAsInt("CMC_LISTINGS_LIMIT", 100),
+			ListingsSort:      getEnv("CMC_LISTINGS_SORT", "market_cap"),
+			Convert:           getEnvAsSlice("CMC_CONVERT", ""),
+			ConvertIDs:        getEnvAsSlice("CMC_CONVERT_ID", ""),
+			MaxConvertPerCall: getEnvAsInt("CMC_MAX_CONVERT_PER_CALL", 1),
+			Aux:               getEnvAsSlice("CMC_AUX", "num_market_pairs,cmc_rank,tags,max_supply,circulating_supply,total_supply,volume_24h_reported"),
+			IDChunkSize:       getEnvAsInt("CMC_ID_CHUNK_SIZE", 100),
+			MaxConcurrency:    getEnvAsInt("CMC_MAX_CONCURRENCY", 4),
+			CreditBudget:      getEnvAsInt("CMC_CREDIT_BUDGET", 0),
+			CreditWindow:      getEnvAsDuration("CMC_CREDIT_WINDOW", "24h"),
+			BackfillCreditCap: getEnvAsInt("CMC_BACKFILL_CREDIT_CAP", 100),
+			BackfillPageSize:  getEnvAsInt("CMC_BACKFILL_PAGE_SIZE", 1000),
+		},
+
+		CoinGecko: CoinGeckoSettings{
+			APIKey:       getEnv("COINGECKO_API_KEY", ""),
+			APIKeyHeader: getEnv("COINGECKO_API_KEY_HEADER", "x-cg-demo-api-key"),
+			BaseURL:      getEnv("COINGECKO_BASE_URL", "https://api.coingecko.com/api/v3"),
+			Endpoint:     getEnv("COINGECKO_ENDPOINT", "markets"),
+			VsCurrencies: getEnvAsSlice("COINGECKO_VS_CURRENCIES", "usd"),
+			IDs:          getEnvAsIDMap("COINGECKO_IDS", "1:bitcoin,1027:ethereum,5994:solana,20947:sui,2010:cardano,8916:internet-computer"),
+			RateLimit:    getEnvAsInt("COINGECKO_RATE_LIMIT", 30),
+		},
+
+		Binance: BinanceSettings{
+			BaseURL:     getEnv("BINANCE_BASE_URL", "https://api.binance.com"),
+			Endpoint:    getEnv("BINANCE_ENDPOINT", "24hr"),
+			Symbols:     getEnvAsIDMap("BINANCE_SYMBOLS", "1:BTC,1027:ETH,5994:SOL,20947:SUI,2010:ADA,8916:ICP"),
+			QuoteAssets: getEnvAsSlice("BINANCE_QUOTE_ASSETS", "USDT,USDC"),
+			RateLimit:   getEnvAsInt("BINANCE_RATE_LIMIT", 600),
+		},
+
+		Coinbase: CoinbaseSettings{
+			BaseURL:         getEnv("COINBASE_BASE_URL", "https://api.exchange.coinbase.com"),
+			Currencies:      getEnvAsIDMap("COINBASE_CURRENCIES", "1:BTC,1027:ETH,5994:SOL,20947:SUI,2010:ADA,8916:ICP"),
+			QuoteCurrencies: getEnvAsSlice("COINBASE_QUOTE_CURRENCIES", "USD"),
+			RateLimit:       getEnvAsInt("COINBASE_RATE_LIMIT", 10),
+		},
+
+		Kraken: KrakenSettings{
+			BaseURL:         getEnv("KRAKEN_BASE_URL", "https://api.kraken.com"),
+			Assets:          getEnvAsIDMap("KRAKEN_ASSETS", "1:BTC,1027:ETH,5994:SOL,20947:SUI,2010:ADA,8916:ICP"),
+			QuoteCurrencies: getEnvAsSlice("KRAKEN_QUOTE_CURRENCIES", "USD"),
+			RateLimit:       getEnvAsInt("KRAKEN_RATE_LIMIT", 60),
 		},
 
 		AppCfg: AppSettings{
@@ -69,10 +228,27 @@ func NewAppConfig() *AppConfig {
 		},
 
 		Interval: IntervalSettings{
-			TickerInterval: getEnvAsDuration("TICKER_INTERVAL", "2m"),
-			MapperInterval: getEnvAsDuration("MAPPER_INTERVAL", "24h"),
+			TickerInterval:        getEnvAsDuration("TICKER_INTERVAL", "2m"),
+			MapperInterval:        getEnvAsDuration("MAPPER_INTERVAL", "24h"),
+			StaleAfter:            getEnvAsDuration("TICKER_STALE_AFTER", "30m"),
+			DefaultTier:           getEnv("TICKER_DEFAULT_TIER", "normal"),
+			SyncTimeout:           getEnvAsDuration("TICKER_SYNC_TIMEOUT", "0"),
+			GapScanInterval:       getEnvAsDuration("TICKER_GAP_SCAN_INTERVAL", "1h"),
+			GapLookback:           getEnvAsDuration("TICKER_GAP_LOOKBACK", "24h"),
+			GapTolerance:          getEnvAsFloat("TICKER_GAP_TOLERANCE", 2),
+			GlobalMetricsInterval: getEnvAsDuration("TICKER_GLOBAL_METRICS_INTERVAL", "15m"),
+		},
+
+		Validate: ValidationSettings{
+			RejectNonPositivePrice: getEnv("VALIDATE_REJECT_NON_POSITIVE_PRICE", "true") == "true",
+			RejectNonFinite:        getEnv("VALIDATE_REJECT_NON_FINITE", "true") == "true",
+			MaxPriceMovePct:        getEnvAsFloat("VALIDATE_MAX_PRICE_MOVE_PCT", 50),
+			PriceMoveConfirmations: getEnvAsInt("VALIDATE_PRICE_MOVE_CONFIRMATIONS", 2),
+			MarketCapTolerancePct:  getEnvAsFloat("VALIDATE_MARKET_CAP_TOLERANCE_PCT", 5),
 		},
 	}
+	app.Interval.Tiers = getEnvAsTiers("TICKER_TIERS", app.Interval.DefaultTier, app.Interval.TickerInterval)
+	return app
 }
 
 // getEnv() function to get env variables from .env file
@@ -94,3 +270,69 @@ func getEnvAsDuration(key, defaultValue string) time.Duration {
 	}
 	return duration
 }
+
+// getEnvAsInt() function to get env variables as int from .env file
+func getEnvAsInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsFloat() function to get env variables as float64 from .env file
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsTiers() function to get polling tiers (name:interval,...) from .env file.
+// Invalid entries are skipped. Returns a single defaultTier polled every defaultInterval if no tier is valid.
+func getEnvAsTiers(key, defaultTier string, defaultInterval time.Duration) []TierSettings {
+	var tiers []TierSettings
+	seen := make(map[string]bool)
+	for _, v := range getEnvAsSlice(key, "") {
+		name, interval, ok := strings.Cut(v, ":")
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		name = strings.TrimSpace(name)
+		if !ok || err != nil || duration <= 0 || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tiers = append(tiers, TierSettings{Name: name, Interval: duration})
+	}
+	if len(tiers) == 0 {
+		tiers = []TierSettings{{Name: defaultTier, Interval: defaultInterval}}
+	}
+	return tiers
+}
+
+// getEnvAsIDMap() function to get a mapping of CMC ID's to provider identifiers (cmc_id:value,...) from .env file.
+// Invalid entries are skipped.
+func getEnvAsIDMap(key, defaultValue string) map[int]string {
+	ids := make(map[int]string)
+	for _, v := range getEnvAsSlice(key, defaultValue) {
+		id, value, ok := strings.Cut(v, ":")
+		cmcID, err := strconv.Atoi(strings.TrimSpace(id))
+		value = strings.TrimSpace(value)
+		if !ok || err != nil || cmcID <= 0 || value == "" {
+			continue
+		}
+		ids[cmcID] = value
+	}
+	return ids
+}
+
+// getEnvAsSlice() function to get comma separated env variables as a slice from .env file
+func getEnvAsSlice(key, defaultValue string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -1,10 +1,19 @@
 package coins
 
-import "log/slog"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/jdbdev/moonramp-ticker/db"
+)
 
 type CoinInterface interface {
 	InitializeCoinTable() error
 	AddTrackedCoin(symbol string) error
+	GetTrackedCMCIDs(ctx context.Context) ([]int, error)
+	GetTrackedCoins(ctx context.Context) ([]TrackedCoin, error)
 }
 
 type CoinService struct {
@@ -26,3 +35,63 @@ func (c *CoinService) AddTrackedCoin(symbol string) error {
 	c.logger.Info("Adding coin to table", "symbol", symbol)
 	return nil
 }
+
+// GetTrackedCMCIDs returns the Coinmarketcap ID's of enabled coins in the tracked_coins table.
+// Returns an empty list if the database is not connected (USE_DB=false).
+func (c *CoinService) GetTrackedCMCIDs(ctx context.Context) ([]int, error) {
+	if !db.IsConnected() {
+		c.logger.Warn("Database not connected - no tracked coins")
+		return nil, nil
+	}
+
+	rows, err := db.GetDatabase().GetDB().QueryContext(ctx,
+		"SELECT cmc_id FROM tracked_coins WHERE enabled = TRUE ORDER BY cmc_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracked coins: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan tracked coin: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tracked coins: %w", err)
+	}
+	return ids, nil
+}
+
+// GetTrackedCoins returns the enabled coins in the tracked_coins table including their polling tier.
+// Returns an empty list if the database is not connected (USE_DB=false).
+func (c *CoinService) GetTrackedCoins(ctx context.Context) ([]TrackedCoin, error) {
+	if !db.IsConnected() {
+		c.logger.Warn("Database not connected - no tracked coins")
+		return nil, nil
+	}
+
+	rows, err := db.GetDatabase().GetDB().QueryContext(ctx,
+		"SELECT id, cmc_id, symbol, name, enabled, tier, created_at FROM tracked_coins WHERE enabled = TRUE ORDER BY cmc_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracked coins: %w", err)
+	}
+	defer rows.Close()
+
+	var coins []TrackedCoin
+	for rows.Next() {
+		var coin TrackedCoin
+		var createdAt sql.NullTime
+		if err := rows.Scan(&coin.ID, &coin.CmCID, &coin.Symbol, &coin.Name, &coin.Enableed, &coin.Tier, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tracked coin: %w", err)
+		}
+		coin.CreatedAt = createdAt.Time
+		coins = append(coins, coin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tracked coins: %w", err)
+	}
+	return coins, nil
+}
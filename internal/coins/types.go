@@ -9,5 +9,6 @@ type TrackedCoin struct {
 	Symbol    string
 	Name      string
 	Enableed  bool      // coin istracked or not
+	Tier      string    // polling tier (ex. hot, normal, cold)
 	CreatedAt time.Time // initial creation date in DB table
 }
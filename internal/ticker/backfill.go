@@ -0,0 +1,400 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Backfill fills coin_quote_history for one coin from /v2/cryptocurrency/quotes/historical so newly tracked coins
+// have history (and candles) right away. The range is fetched page by page (CMC_BACKFILL_PAGE_SIZE points per call)
+// and every page is written in one transaction together with the backfill_progress cursor, so an interrupted
+// backfill resumes after the last written point. History inserts are idempotent (unique_coin_quote_history).
+// CMC charges 1 credit per 100 historical data points. A backfill stops before a page would exceed
+// CMC_BACKFILL_CREDIT_CAP credits (0 disables the cap) or the shared credit budget and can be resumed later.
+// The cap counts the credits of every run of the same backfill (backfill_progress.credits_used), so resuming a
+// capped backfill does not get a fresh cap.
+
+// Backfill statuses stored in backfill_progress.status
+const (
+	BackfillRunning = "running"
+	BackfillDone    = "done"
+	BackfillCapped  = "capped" // stopped at the credit cap or budget, resumes on a later run with credits left
+	BackfillFailed  = "failed"
+)
+
+// defaultBackfillInterval is used when BackfillRequest.Interval is empty
+const defaultBackfillInterval = "1h"
+
+// maxBackfillPageSize is the max count accepted by the historical endpoint
+const maxBackfillPageSize = 10000
+
+const (
+	// upsertBackfillProgressSQL creates the progress row of a backfill or returns the existing one
+	upsertBackfillProgressSQL = `
+INSERT INTO backfill_progress (cmc_id, currency, interval, time_start, time_end, status)
+VALUES ($1, $2, $3, $4, $5, 'running')
+ON CONFLICT ON CONSTRAINT unique_backfill_progress DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+RETURNING id, cursor, status, credits_used`
+
+	updateBackfillProgressSQL = `
+UPDATE backfill_progress SET
+    cursor = COALESCE($2, cursor),
+    status = $3,
+    points_written = points_written + $4,
+    credits_used = credits_used + $5,
+    last_error = $6,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1`
+
+	// ensureCoinInfoSQL returns the coin_info id of a coin without overwriting the fields kept up to date by Sync.
+	// Also used by quotes only providers. Historical and quotes only responses have no slug, the next full sync fills it in.
+	ensureCoinInfoSQL = `
+INSERT INTO coin_info (cmc_id, name, symbol, slug)
+VALUES ($1, $2, $3, '')
+ON CONFLICT (cmc_id) DO UPDATE SET cmc_id = EXCLUDED.cmc_id
+RETURNING id`
+)
+
+// backfillParams holds the backfill settings (CMC_HISTORICAL_URL, CMC_BACKFILL_*)
+type backfillParams struct {
+	url       string
+	creditCap int // max credits per backfill run, 0 disables the cap
+	pageSize  int // data points per historical call
+}
+
+// BackfillRequest holds the coin, range and interval of a backfill.
+type BackfillRequest struct {
+	CmcID    int
+	From     time.Time
+	To       time.Time
+	Interval string // CMC interval ("5m", "1h", "daily", ...), defaults to 1h
+	Currency string // convert symbol or convert_id, defaults to the first configured convert target
+}
+
+// BackfillReport holds the result of a backfill run.
+type BackfillReport struct {
+	CmcID         int
+	Currency      string
+	Interval      string
+	From          time.Time
+	To            time.Time
+	Resumed       bool      // continued from a previous run
+	Cursor        time.Time // last point written, zero if none
+	Status        string
+	PointsFetched int
+	PointsWritten int // new coin_quote_history rows
+	CreditCount   int
+	PriorCredits  int // credits spent by previous runs, counted against the cap
+	Pages         int
+	// Values stored as NULL (or points dropped for prices) because they do not fit their NUMERIC column
+	NumericOverflows []NumericOverflow
+}
+
+// backfillProgress is the backfill_progress row of a backfill
+type backfillProgress struct {
+	id          int
+	cursor      time.Time // last point written, zero if nothing written yet
+	status      string
+	creditsUsed int
+}
+
+// backfillPoint is one historical quote of the backfilled coin
+type backfillPoint struct {
+	time  time.Time
+	quote CoinQuote
+}
+
+// Backfill fetches the historical quotes of one coin over [From, To) and writes them to coin_quote_history.
+// Returns a report on error too. Returns ErrCreditBudgetExceeded when the backfill stopped at the credit cap.
+func (t *TickerService) Backfill(ctx context.Context, req BackfillRequest) (*BackfillReport, error) {
+	return t.runBackfill(ctx, t.database(), req, 0)
+}
+
+// runBackfill runs Backfill on sqlDB. spent is the number of credits the caller already charged against the cap
+// (RepairGaps shares one cap across the backfills of a pass).
+func (t *TickerService) runBackfill(ctx context.Context, sqlDB *sql.DB, req BackfillRequest, spent int) (report *BackfillReport, err error) {
+	req = t.backfillDefaults(req)
+	report = &BackfillReport{
+		CmcID: req.CmcID, Currency: req.Currency, Interval: req.Interval, From: req.From, To: req.To,
+		Status: BackfillRunning,
+	}
+	if req.CmcID <= 0 {
+		return report, fmt.Errorf("backfill: %w: %d", ErrInvalidID, req.CmcID)
+	}
+	if !req.From.Before(req.To) {
+		return report, fmt.Errorf("backfill: invalid range %v - %v", req.From, req.To)
+	}
+	if t.backfill.url == "" {
+		return report, fmt.Errorf("backfill: no historical URL configured")
+	}
+	if sqlDB == nil {
+		return report, fmt.Errorf("backfill: database not connected")
+	}
+
+	progress, err := loadBackfillProgress(ctx, sqlDB, req)
+	if err != nil {
+		return report, err
+	}
+	progressID := progress.id
+	if progress.status == BackfillDone {
+		report.Status = BackfillDone
+		report.Cursor = progress.cursor
+		return report, nil
+	}
+	report.PriorCredits = progress.creditsUsed
+	start := req.From
+	if !progress.cursor.IsZero() {
+		report.Resumed = true
+		report.Cursor = progress.cursor
+		start = progress.cursor
+	}
+
+	// Record failures on the progress row, the cursor keeps the last written page
+	defer func() {
+		if err == nil || report.Status == BackfillCapped {
+			return
+		}
+		report.Status = BackfillFailed
+		if _, dbErr := sqlDB.ExecContext(context.WithoutCancel(ctx), updateBackfillProgressSQL,
+			progressID, nil, BackfillFailed, 0, 0, err.Error()); dbErr != nil {
+			t.logger.Error("failed to record backfill failure", "cmc_id", req.CmcID, "error", dbErr)
+		}
+	}()
+
+	for {
+		credits := estimateHistoricalCredits(t.backfill.pageSize)
+		used := spent + report.PriorCredits + report.CreditCount
+		if t.backfill.creditCap > 0 && used+credits > t.backfill.creditCap {
+			report.Status = BackfillCapped
+			err = fmt.Errorf("%w: backfill cap of %d credits reached after %d credits", ErrCreditBudgetExceeded, t.backfill.creditCap, used)
+		} else if budgetErr := t.budget.allow(t.now(), credits); budgetErr != nil {
+			report.Status = BackfillCapped
+			err = budgetErr
+		}
+		if report.Status == BackfillCapped {
+			if _, dbErr := sqlDB.ExecContext(ctx, updateBackfillProgressSQL, progressID, nil, BackfillCapped, 0, 0, err.Error()); dbErr != nil {
+				return report, fmt.Errorf("failed to update backfill progress: %w", dbErr)
+			}
+			t.logger.Warn("Backfill stopped at credit cap", "cmc_id", req.CmcID, "cursor", report.Cursor, "credit_count", report.CreditCount)
+			return report, err
+		}
+
+		data, err := t.callHistorical(ctx, req, start)
+		if err != nil {
+			return report, err
+		}
+		resp, err := t.DecodeHistorical(data)
+		if err != nil {
+			return report, err
+		}
+		report.Pages++
+		report.CreditCount += resp.Status.CreditCount
+		t.budget.record(t.now(), resp.Status.CreditCount)
+
+		coin, ok := resp.Data[strconv.Itoa(req.CmcID)]
+		if !ok {
+			return report, fmt.Errorf("backfill cmc_id %d: %w", req.CmcID, ErrMissingUpstream)
+		}
+		points, err := historicalPoints(coin, req.Currency, start, req.To)
+		if err != nil {
+			return report, fmt.Errorf("backfill cmc_id %d: %w", req.CmcID, err)
+		}
+		report.PointsFetched += len(points)
+
+		// Done when the page is short (end of range) or makes no progress past the cursor
+		next := start
+		if n := len(points); n > 0 {
+			next = points[n-1].time
+		}
+		points, overflows := fitPoints(coin, req.Currency, points)
+		report.NumericOverflows = append(report.NumericOverflows, overflows...)
+		done := len(coin.Quotes) < t.backfill.pageSize || !next.After(start)
+		status := BackfillRunning
+		if done {
+			status = BackfillDone
+		}
+
+		written, err := writeBackfillPage(ctx, sqlDB, progressID, coin, req.Currency, points, next, status, resp.Status.CreditCount)
+		if err != nil {
+			return report, err
+		}
+		report.PointsWritten += written
+		if next.After(start) {
+			report.Cursor = next
+		}
+		start = next
+		if done {
+			report.Status = BackfillDone
+			t.logger.Info("Backfill finished", "cmc_id", req.CmcID, "currency", req.Currency, "interval", req.Interval,
+				"points_written", report.PointsWritten, "pages", report.Pages, "credit_count", report.CreditCount)
+			return report, nil
+		}
+	}
+}
+
+// backfillDefaults fills in the default interval and currency and aligns the range to whole seconds in UTC
+func (t *TickerService) backfillDefaults(req BackfillRequest) BackfillRequest {
+	if req.Interval == "" {
+		req.Interval = defaultBackfillInterval
+	}
+	if req.Currency == "" {
+		req.Currency = "USD"
+		if len(t.cmc.convert) > 0 && len(t.cmc.convert[0].values) > 0 {
+			req.Currency = t.cmc.convert[0].values[0]
+		}
+	}
+	req.From = req.From.UTC().Truncate(time.Second)
+	req.To = req.To.UTC().Truncate(time.Second)
+	return req
+}
+
+// estimateHistoricalCredits returns the credits CMC charges for a page of points historical data points
+func estimateHistoricalCredits(points int) int {
+	return (max(points, 1) + creditsPerCoins - 1) / creditsPerCoins
+}
+
+// historicalPoints returns the quotes of coin in currency within [from, to) sorted by time
+func historicalPoints(coin HistoricalCoin, currency string, from, to time.Time) ([]backfillPoint, error) {
+	var points []backfillPoint
+	for _, q := range coin.Quotes {
+		quote, ok := q.Quote[currency]
+		if !ok {
+			continue
+		}
+		ts := q.Timestamp.Time
+		if q.Timestamp.IsZero() || ts.Before(from) || !ts.Before(to) {
+			continue
+		}
+		quote.LastUpdated = q.Timestamp
+		points = append(points, backfillPoint{time: ts, quote: quote})
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].time.Before(points[i-1].time) {
+			return nil, fmt.Errorf("historical quotes out of order at %v", points[i].time)
+		}
+	}
+	return points, nil
+}
+
+// fitPoints drops the points whose price does not fit its column and sets other values that do not fit to null
+func fitPoints(coin HistoricalCoin, currency string, points []backfillPoint) ([]backfillPoint, []NumericOverflow) {
+	r := &overflowRecorder{cmcID: coin.CmcID, symbol: coin.Symbol, currency: currency}
+	fitted := make([]backfillPoint, 0, len(points))
+	for _, p := range points {
+		quote, ok := r.fitQuote(p.quote)
+		if !ok {
+			continue
+		}
+		p.quote = quote
+		fitted = append(fitted, p)
+	}
+	return fitted, r.overflows
+}
+
+// loadBackfillProgress creates or loads the progress row of req
+func loadBackfillProgress(ctx context.Context, sqlDB *sql.DB, req BackfillRequest) (backfillProgress, error) {
+	var progress backfillProgress
+	var cursor sql.NullTime
+	err := sqlDB.QueryRowContext(ctx, upsertBackfillProgressSQL,
+		req.CmcID, req.Currency, req.Interval, req.From, req.To,
+	).Scan(&progress.id, &cursor, &progress.status, &progress.creditsUsed)
+	if err != nil {
+		return backfillProgress{}, fmt.Errorf("failed to load backfill progress: %w", err)
+	}
+	progress.cursor = cursor.Time.UTC()
+	return progress, nil
+}
+
+// writeBackfillPage writes one page of points to coin_quote_history, updates their candles and advances the
+// progress cursor to cursor in a single transaction. Returns the number of new history rows.
+func writeBackfillPage(ctx context.Context, sqlDB *sql.DB, progressID int, coin HistoricalCoin, currency string, points []backfillPoint, cursor time.Time, status string, credits int) (written int, err error) {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if len(points) > 0 {
+		var coinID int
+		if err = tx.QueryRowContext(ctx, ensureCoinInfoSQL, coin.CmcID, coin.Name, coin.Symbol).Scan(&coinID); err != nil {
+			return 0, fmt.Errorf("failed to upsert coin_info for cmc_id %d: %w", coin.CmcID, err)
+		}
+		for _, p := range points {
+			quote := p.quote
+			res, err := tx.ExecContext(ctx, insertCoinQuoteHistorySQL,
+				coinID, currency, quote.Price, quote.MarketCap, quote.FullyDilutedMarketCap, quote.Volume24H,
+				quote.Volume24HReported, quote.VolumeChange24H, quote.PercentChange1H, quote.PercentChange24h,
+				quote.PercentChange7d, quote.PercentChange30d, quote.PercentChange60d, quote.PercentChange90d,
+				quote.Tvl, p.time,
+			)
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert coin_quote_history for cmc_id %d %s: %w", coin.CmcID, currency, err)
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				continue
+			}
+			written++
+			point := candlePoint{coinID: coinID, currency: currency, price: quote.Price, volume24h: quote.Volume24H, time: p.time}
+			if err := updateCandles(ctx, tx, point); err != nil {
+				return 0, fmt.Errorf("failed to update candles for cmc_id %d %s: %w", coin.CmcID, currency, err)
+			}
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, updateBackfillProgressSQL, progressID, cursor, status, written, credits, nil); err != nil {
+		return 0, fmt.Errorf("failed to update backfill progress: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return written, nil
+}
+
+// callHistorical gets one page of historical quotes starting at start and returns a []byte of the JSON response
+func (t *TickerService) callHistorical(ctx context.Context, req BackfillRequest, start time.Time) ([]byte, error) {
+	q := url.Values{}
+	q.Add("id", strconv.Itoa(req.CmcID))
+	q.Add("time_start", start.Format(time.RFC3339))
+	q.Add("time_end", req.To.Format(time.RFC3339))
+	q.Add("interval", req.Interval)
+	q.Add("count", strconv.Itoa(t.backfill.pageSize))
+	// Currencies synced with convert_id are stored under their CMC ID
+	if _, err := strconv.Atoi(req.Currency); err == nil {
+		q.Add("convert_id", req.Currency)
+	} else {
+		q.Add("convert", req.Currency)
+	}
+	return t.cmc.rest.get(ctx, t.backfill.url, q)
+}
+
+// DecodeHistorical decodes a historical quotes JSON []byte into a HistoricalResponse.
+// API errors are returned as *APIError like DecodeData.
+func (t *TickerService) DecodeHistorical(data []byte) (*HistoricalResponse, error) {
+	var resp HistoricalResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.logger.Error("failed to unmarshal historical response", "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	if err := checkStatus(resp.Status); err != nil {
+		t.logger.Error("Coinmarketcap API returned error", "error", err, "credit_count", resp.Status.CreditCount)
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// backfillPageSize clamps the configured page size to the range accepted by the historical endpoint
+func backfillPageSize(size int) int {
+	if size <= 0 {
+		return 1000
+	}
+	return min(size, maxBackfillPageSize)
+}
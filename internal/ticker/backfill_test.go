@@ -0,0 +1,195 @@
+package ticker
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestCallHistorical tests the historical query parameters and decoding of the v2 response
+func TestCallHistorical(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		got = map[string]string{
+			"id": q.Get("id"), "time_start": q.Get("time_start"), "time_end": q.Get("time_end"),
+			"interval": q.Get("interval"), "count": q.Get("count"),
+			"convert": q.Get("convert"), "convert_id": q.Get("convert_id"),
+		}
+		w.Write([]byte(`{"status":{"error_code":0,"credit_count":1},"data":{"1":{"id":1,"name":"Bitcoin","symbol":"BTC",
+			"quotes":[{"timestamp":"2024-01-01T00:00:00.000Z","quote":{"USD":{"price":42000,"volume_24h":1000,"timestamp":"2024-01-01T00:00:00.000Z"}}}]}}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{HistoricalURL: server.URL, BackfillPageSize: 500}}
+	service := NewTickerService(cfg, &fakeCoins{}, testLogger(), server.Client())
+
+	req := service.backfillDefaults(BackfillRequest{
+		CmcID: 1,
+		From:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+	data, err := service.callHistorical(context.Background(), req, req.From)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := map[string]string{
+		"id": "1", "time_start": "2024-01-01T00:00:00Z", "time_end": "2024-01-02T00:00:00Z",
+		"interval": "1h", "count": "500", "convert": "USD",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Expected %s=%s, got %s", k, v, got[k])
+		}
+	}
+
+	resp, err := service.DecodeHistorical(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	quotes := resp.Data["1"].Quotes
+	if len(quotes) != 1 || quotes[0].Quote["USD"].Price != "42000" {
+		t.Errorf("Expected one BTC quote at 42000, got %+v", quotes)
+	}
+
+	t.Run("convert_id currency", func(t *testing.T) {
+		req.Currency = "2781"
+		if _, err := service.callHistorical(context.Background(), req, req.From); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got["convert_id"] != "2781" || got["convert"] != "" {
+			t.Errorf("Expected convert_id=2781 and no convert, got %s and %s", got["convert_id"], got["convert"])
+		}
+	})
+
+	t.Run("convert_id only default currency", func(t *testing.T) {
+		cfg := &config.AppConfig{CMC: config.CMCSettings{ConvertIDs: []string{"2781"}}}
+		service := NewTickerService(cfg, &fakeCoins{}, testLogger(), server.Client())
+		if got := service.backfillDefaults(BackfillRequest{CmcID: 1}).Currency; got != "2781" {
+			t.Errorf("Expected currency 2781, got %s", got)
+		}
+	})
+
+	t.Run("API error", func(t *testing.T) {
+		_, err := service.DecodeHistorical([]byte(`{"status":{"error_code":1006,"error_message":"plan"}}`))
+		if !errors.Is(err, ErrPlanLimit) {
+			t.Errorf("Expected ErrPlanLimit, got %v", err)
+		}
+	})
+}
+
+// TestHistoricalPoints tests that points outside the range and other currencies are skipped
+func TestHistoricalPoints(t *testing.T) {
+	coin := HistoricalCoin{CmcID: 1, Quotes: []HistoricalQuote{
+		{Timestamp: cmcTime("2024-01-01T00:00:00.000Z"), Quote: map[string]CoinQuote{"USD": {Price: "1"}}},
+		{Timestamp: cmcTime("2024-01-01T01:00:00.000Z"), Quote: map[string]CoinQuote{"USD": {Price: "2"}}},
+		{Timestamp: cmcTime("2024-01-01T02:00:00.000Z"), Quote: map[string]CoinQuote{"EUR": {Price: "3"}}},
+		{Timestamp: cmcTime("2024-01-01T03:00:00.000Z"), Quote: map[string]CoinQuote{"USD": {Price: "4"}}},
+	}}
+	from := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	points, err := historicalPoints(coin, "USD", from, to)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(points) != 1 || points[0].quote.Price != "2" || !points[0].time.Equal(from) {
+		t.Errorf("Expected only the 01:00 USD point, got %+v", points)
+	}
+	if !points[0].quote.LastUpdated.Equal(from) {
+		t.Errorf("Expected last_updated from the point timestamp, got %v", points[0].quote.LastUpdated)
+	}
+}
+
+// TestBackfillCredits tests the credit estimate per page and the clamped page size
+func TestBackfillCredits(t *testing.T) {
+	tests := []struct{ points, want int }{{1, 1}, {100, 1}, {101, 2}, {1000, 10}}
+	for _, tt := range tests {
+		if got := estimateHistoricalCredits(tt.points); got != tt.want {
+			t.Errorf("estimateHistoricalCredits(%d) = %d, want %d", tt.points, got, tt.want)
+		}
+	}
+	if got := backfillPageSize(50000); got != maxBackfillPageSize {
+		t.Errorf("Expected page size clamped to %d, got %d", maxBackfillPageSize, got)
+	}
+}
+
+// TestBackfillRequiresDatabase tests that Backfill refuses to run without a database (progress is stored there)
+func TestBackfillRequiresDatabase(t *testing.T) {
+	cfg := &config.AppConfig{CMC: config.CMCSettings{HistoricalURL: "http://localhost"}}
+	service := NewTickerService(cfg, &fakeCoins{}, testLogger(), http.DefaultClient)
+
+	report, err := service.Backfill(context.Background(), BackfillRequest{
+		CmcID: 1, From: time.Now().Add(-time.Hour), To: time.Now(),
+	})
+	if err == nil {
+		t.Fatal("Expected an error without a database")
+	}
+	if report == nil || report.CmcID != 1 {
+		t.Errorf("Expected a report on error, got %+v", report)
+	}
+}
+
+// TestBackfillCreditCapResume tests that the credits of previous runs and of the calling pass count against the cap,
+// so a capped backfill is not resumed with a fresh cap
+func TestBackfillCreditCapResume(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"status":{"error_code":0,"credit_count":10},"data":{}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{HistoricalURL: server.URL, BackfillPageSize: 1000, BackfillCreditCap: 10}}
+	service := NewTickerService(cfg, &fakeCoins{}, testLogger(), server.Client())
+	req := BackfillRequest{
+		CmcID: 1,
+		From:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	cursor := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		cursor      any
+		creditsUsed int64
+		spent       int
+	}{
+		{"resumed capped backfill", cursor, 10, 0},
+		{"cap spent by the pass", nil, 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			r := &recordingDB{rows: func(stmt string) *recordingRows {
+				if stmt != "INSERT INTO backfill_progress" {
+					return nil
+				}
+				return &recordingRows{
+					columns: []string{"id", "cursor", "status", "credits_used"},
+					values:  [][]driver.Value{{int64(1), tt.cursor, BackfillCapped, tt.creditsUsed}},
+				}
+			}}
+			report, err := service.runBackfill(context.Background(), newRecordingDB(t, r), req, tt.spent)
+			if !errors.Is(err, ErrCreditBudgetExceeded) {
+				t.Fatalf("Expected ErrCreditBudgetExceeded, got %v", err)
+			}
+			if n := calls.Load(); n != 0 {
+				t.Errorf("Expected no historical call, got %d", n)
+			}
+			if report.Status != BackfillCapped || report.PriorCredits != int(tt.creditsUsed) {
+				t.Errorf("Expected capped status and %d prior credits, got %+v", tt.creditsUsed, report)
+			}
+			if n := r.count("UPDATE backfill_progress SET"); n != 1 {
+				t.Errorf("Expected the capped status recorded once, got %d updates", n)
+			}
+		})
+	}
+}
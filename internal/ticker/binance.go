@@ -0,0 +1,297 @@
+package ticker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// Binance spot API documentation: https://developers.binance.com/docs/binance-spot-api-docs/rest-api/market-data-endpoints
+// TICKER_PROVIDER=binance syncs exchange prices for the majors from Binance spot tickers. Tracked coins are mapped to
+// Binance base assets in BINANCE_SYMBOLS (cmc_id:base_asset) and paired with every quote asset in BINANCE_QUOTE_ASSETS
+// (ex. BTC -> BTCUSDT, BTCUSDC). Quotes are stored under the quote asset as currency (USDT, USDC).
+// BINANCE_ENDPOINT selects the endpoint:
+// "24hr" (/api/v3/ticker/24hr) fills price, 24h volume (in the quote asset) and 24h change.
+// "price" (/api/v3/ticker/price) fills the price only, at a lower request weight.
+// Binance fails a whole call if one symbol does not exist. The pairs of that call are then fetched one by one so a
+// bad mapping only drops its own pair. Binance has no coin fields, results are quotes only.
+
+// Binance endpoints selectable in BINANCE_ENDPOINT
+const (
+	BinanceTicker24h = "24hr"
+	BinancePrice     = "price"
+)
+
+// binanceMaxSymbols is the max number of symbols per call (keeps the request weight and URL length low)
+const binanceMaxSymbols = 100
+
+// binanceInvalidSymbol is the Binance error code for an unknown symbol
+const binanceInvalidSymbol = -1121
+
+// BinanceProvider implements QuoteProvider with the Binance spot ticker endpoints.
+type BinanceProvider struct {
+	rest        *restClient
+	endpoint    string            // BinanceTicker24h or BinancePrice
+	symbols     map[string]string // CMC ID -> base asset
+	quoteAssets []string
+	now         func() time.Time
+	logger      *slog.Logger
+}
+
+// binancePair is a trading pair of a tracked coin
+type binancePair struct {
+	cmcID string
+	base  string
+	quote string // quote asset, stored as currency
+}
+
+// binanceTicker holds one symbol from /api/v3/ticker/24hr or /api/v3/ticker/price. Numbers are JSON strings.
+// The price endpoint only returns Symbol and Price.
+type binanceTicker struct {
+	Symbol             string  `json:"symbol"`
+	Price              Decimal `json:"price"`
+	LastPrice          Decimal `json:"lastPrice"`
+	PriceChangePercent Decimal `json:"priceChangePercent"`
+	QuoteVolume        Decimal `json:"quoteVolume"`
+	CloseTime          int64   `json:"closeTime"` // unix milliseconds
+}
+
+// binanceErrorResponse holds a Binance error response
+type binanceErrorResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// NewBinanceProvider creates a new instance of the BinanceProvider struct
+func NewBinanceProvider(bn config.BinanceSettings, client *http.Client, logger *slog.Logger) *BinanceProvider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	endpoint := bn.Endpoint
+	if endpoint != BinanceTicker24h && endpoint != BinancePrice {
+		logger.Warn("Unknown Binance endpoint - using 24hr", "endpoint", endpoint)
+		endpoint = BinanceTicker24h
+	}
+	if len(bn.Symbols) == 0 {
+		logger.Warn("No Binance symbols provided - requires BINANCE_SYMBOLS to map tracked coins")
+	}
+	quoteAssets := make([]string, 0, len(bn.QuoteAssets))
+	for _, q := range bn.QuoteAssets {
+		quoteAssets = append(quoteAssets, strings.ToUpper(q))
+	}
+	if len(quoteAssets) == 0 {
+		quoteAssets = []string{"USDT"}
+	}
+	symbols := providerIDs(bn.Symbols)
+	for id, base := range symbols {
+		symbols[id] = strings.ToUpper(base)
+	}
+	return &BinanceProvider{
+		rest: &restClient{
+			provider: ProviderBinance,
+			baseURL:  bn.BaseURL,
+			apiError: binanceError,
+			limiter:  newRateLimiter(bn.RateLimit, time.Minute),
+			client:   client,
+			logger:   logger,
+		},
+		endpoint:    endpoint,
+		symbols:     symbols,
+		quoteAssets: quoteAssets,
+		now:         time.Now,
+		logger:      logger,
+	}
+}
+
+// Name returns the provider name
+func (b *BinanceProvider) Name() string {
+	return ProviderBinance
+}
+
+// EstimateCredits returns 0, Binance calls are rate limited by request weight instead
+func (b *BinanceProvider) EstimateCredits(ids []string) int {
+	return 0
+}
+
+// FetchQuotes fetches the tickers of every pair of the mapped coins in chunks of binanceMaxSymbols. A failing call is
+// reported in ChunkErrors and does not stop the other calls unless the error applies to every call.
+func (b *BinanceProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	pairs := make(map[string]binancePair)
+	var unmapped []string
+	for _, id := range ids {
+		base, ok := b.symbols[id]
+		if !ok {
+			unmapped = append(unmapped, id)
+			continue
+		}
+		for _, quote := range b.quoteAssets {
+			pairs[base+quote] = binancePair{cmcID: id, base: base, quote: quote}
+		}
+	}
+	quotes := &ProviderQuotes{Provider: ProviderBinance, Coins: make(map[string]CoinInfo), QuotesOnly: true}
+	if len(unmapped) > 0 {
+		quotes.ChunkErrors = append(quotes.ChunkErrors, ChunkError{IDs: unmapped, Err: ErrUnmappedCoin})
+	}
+
+	chunks := chunkStrings(sortedKeys(pairs), binanceMaxSymbols)
+	var firstErr error
+	failed := 0
+	for _, chunk := range chunks {
+		if firstErr != nil && isFatalAPIError(firstErr) {
+			// Remaining calls would fail with the same error
+			failed++
+			quotes.ChunkErrors = append(quotes.ChunkErrors, binanceChunkError(chunk, pairs, firstErr))
+			continue
+		}
+		tickers, chunkErrs, err := b.fetchChunk(ctx, chunk, pairs)
+		quotes.ChunkErrors = append(quotes.ChunkErrors, chunkErrs...)
+		b.addTickers(tickers, pairs, quotes.Coins)
+		if err != nil {
+			if len(tickers) == 0 {
+				failed++
+			}
+			if firstErr == nil || isFatalAPIError(err) {
+				firstErr = err
+			}
+		}
+	}
+	if len(chunks) > 0 && failed == len(chunks) {
+		quotes.Coins = nil
+		return quotes, firstErr
+	}
+
+	b.logger.Info("Fetched quotes", "calls", len(chunks), "failed_calls", failed, "coins_count", len(quotes.Coins),
+		"unmapped_count", len(unmapped))
+	return quotes, nil
+}
+
+// fetchChunk fetches the tickers of symbols in one call. One unknown symbol fails the whole call, the symbols are
+// then fetched one by one to keep the valid ones. Returns the failed calls and an error if every symbol failed or the
+// error applies to every call.
+func (b *BinanceProvider) fetchChunk(ctx context.Context, symbols []string, pairs map[string]binancePair) ([]binanceTicker, []ChunkError, error) {
+	tickers, err := b.fetchTickers(ctx, symbols)
+	if err == nil {
+		return tickers, nil, nil
+	}
+	if !errors.Is(err, ErrInvalidID) || len(symbols) == 1 {
+		return nil, []ChunkError{binanceChunkError(symbols, pairs, err)}, err
+	}
+
+	b.logger.Warn("Unknown symbol in call - fetching symbols one by one", "symbols_count", len(symbols))
+	tickers = nil
+	var chunkErrs []ChunkError
+	var firstErr error
+	for i, symbol := range symbols {
+		t, err := b.fetchTickers(ctx, []string{symbol})
+		if isFatalAPIError(err) {
+			// Remaining symbols would fail with the same error
+			chunkErrs = append(chunkErrs, binanceChunkError(symbols[i:], pairs, err))
+			return tickers, chunkErrs, err
+		}
+		if err != nil {
+			chunkErrs = append(chunkErrs, binanceChunkError([]string{symbol}, pairs, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		tickers = append(tickers, t...)
+	}
+	if len(tickers) == 0 {
+		return nil, chunkErrs, firstErr
+	}
+	return tickers, chunkErrs, nil
+}
+
+// fetchTickers calls the configured ticker endpoint for symbols
+func (b *BinanceProvider) fetchTickers(ctx context.Context, symbols []string) ([]binanceTicker, error) {
+	list, err := json.Marshal(symbols)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Add("symbols", string(list))
+	path := "/api/v3/ticker/24hr"
+	if b.endpoint == BinancePrice {
+		path = "/api/v3/ticker/price"
+	}
+	data, err := b.rest.get(ctx, path, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickers []binanceTicker
+	if err := json.Unmarshal(data, &tickers); err != nil {
+		b.logger.Error("failed to unmarshal ticker response", "error", err)
+		return nil, decodeError(ProviderBinance, err)
+	}
+	return tickers, nil
+}
+
+// addTickers adds the quote of every ticker to the coin of its pair
+func (b *BinanceProvider) addTickers(tickers []binanceTicker, pairs map[string]binancePair, coins map[string]CoinInfo) {
+	now := CMCTime{Time: b.now().UTC()}
+	for _, t := range tickers {
+		pair, ok := pairs[t.Symbol]
+		if !ok {
+			continue
+		}
+		quote := CoinQuote{Price: t.Price, LastUpdated: now}
+		if b.endpoint == BinanceTicker24h {
+			quote = CoinQuote{
+				Price:            t.LastPrice,
+				Volume24H:        t.QuoteVolume,
+				PercentChange24h: t.PriceChangePercent,
+				LastUpdated:      CMCTime{Time: time.UnixMilli(t.CloseTime).UTC()},
+			}
+		}
+		coin := coins[pair.cmcID]
+		if coin.Quote == nil {
+			cmcID, _ := strconv.Atoi(pair.cmcID)
+			coin = CoinInfo{CmcID: cmcID, Symbol: pair.base, Quote: make(map[string]CoinQuote)}
+		}
+		coin.Quote[pair.quote] = quote
+		coins[pair.cmcID] = coin
+	}
+}
+
+// binanceChunkError returns the ChunkError of a failed call with the CMC ID's of its symbols
+func binanceChunkError(symbols []string, pairs map[string]binancePair, err error) ChunkError {
+	seen := make(map[string]bool)
+	var ids, quotes []string
+	for _, symbol := range symbols {
+		pair := pairs[symbol]
+		if !seen[pair.cmcID] {
+			seen[pair.cmcID] = true
+			ids = append(ids, pair.cmcID)
+		}
+		if !seen["quote:"+pair.quote] {
+			seen["quote:"+pair.quote] = true
+			quotes = append(quotes, pair.quote)
+		}
+	}
+	return ChunkError{IDs: ids, Convert: strings.Join(quotes, ","), Err: err}
+}
+
+// binanceError maps a Binance error response ({"code":-1121,"msg":"Invalid symbol."}) to an *APIError.
+// Falls back to the HTTP status code if the body is not a Binance error.
+func binanceError(status int, body []byte) *APIError {
+	var resp binanceErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Code == 0 {
+		return newHTTPError(ProviderBinance, status, errorMessage(string(body)))
+	}
+	apiErr := newHTTPError(ProviderBinance, status, errorMessage(resp.Msg))
+	apiErr.Code = resp.Code
+	if resp.Code == binanceInvalidSymbol {
+		apiErr.Err = ErrInvalidID
+	}
+	return apiErr
+}
@@ -0,0 +1,136 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestBinanceTicker24h tests mapping of USDT/USDC pairs onto tracked coins from /api/v3/ticker/24hr
+func TestBinanceTicker24h(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/ticker/24hr" || r.URL.Query().Get("symbols") != `["BTCUSDC","BTCUSDT","ETHUSDC","ETHUSDT"]` {
+			t.Errorf("Unexpected request: %s %s", r.URL.Path, r.URL.Query().Get("symbols"))
+		}
+		w.Write([]byte(`[
+			{"symbol":"BTCUSDT","lastPrice":"67187.33000000","priceChangePercent":"3.640","quoteVolume":"1845123456.12","closeTime":1711356300000},
+			{"symbol":"BTCUSDC","lastPrice":"67190.01000000","priceChangePercent":"3.601","quoteVolume":"145123456.10","closeTime":1711356300000},
+			{"symbol":"ETHUSDT","lastPrice":"3500.50000000","priceChangePercent":"-1.250","quoteVolume":"945123456.00","closeTime":1711356300000}]`))
+	}))
+	defer server.Close()
+
+	provider := NewBinanceProvider(config.BinanceSettings{
+		BaseURL: server.URL, Endpoint: BinanceTicker24h, Symbols: map[int]string{1: "btc", 1027: "ETH"},
+		QuoteAssets: []string{"USDT", "usdc"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "1027", "5994"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !quotes.QuotesOnly {
+		t.Error("Expected quotes only result")
+	}
+	btc := quotes.Coins["1"]
+	if btc.Symbol != "BTC" || btc.Quote["USDT"].Price != "67187.33000000" || btc.Quote["USDC"].Price != "67190.01000000" {
+		t.Errorf("Expected BTC USDT and USDC quotes, got %+v", btc)
+	}
+	usdt := btc.Quote["USDT"]
+	if usdt.Volume24H != "1845123456.12" || usdt.PercentChange24h != "3.640" || usdt.LastUpdated.UnixMilli() != 1711356300000 {
+		t.Errorf("Unexpected BTCUSDT quote: %+v", usdt)
+	}
+	if eth := quotes.Coins["1027"]; len(eth.Quote) != 1 || eth.Quote["USDT"].Price != "3500.50000000" {
+		t.Errorf("Expected only the ETH USDT quote, got %+v", eth.Quote)
+	}
+	if len(quotes.ChunkErrors) != 1 || !errors.Is(quotes.ChunkErrors[0], ErrUnmappedCoin) {
+		t.Errorf("Expected 5994 to be unmapped, got %v", quotes.ChunkErrors)
+	}
+}
+
+// TestBinanceInvalidSymbol tests that an unknown symbol only drops its own pair
+func TestBinanceInvalidSymbol(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbols := r.URL.Query().Get("symbols")
+		if strings.Contains(symbols, "FOOUSDT") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+			return
+		}
+		w.Write([]byte(`[{"symbol":"BTCUSDT","price":"67187.33000000"}]`))
+	}))
+	defer server.Close()
+
+	provider := NewBinanceProvider(config.BinanceSettings{
+		BaseURL: server.URL, Endpoint: BinancePrice, Symbols: map[int]string{1: "BTC", 99: "FOO"}, QuoteAssets: []string{"USDT"},
+	}, server.Client(), testLogger())
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "99"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if btc := quotes.Coins["1"].Quote["USDT"]; btc.Price != "67187.33000000" || !btc.LastUpdated.Equal(now) {
+		t.Errorf("Expected BTCUSDT price at fetch time, got %+v", btc)
+	}
+	if len(quotes.ChunkErrors) != 1 || quotes.ChunkErrors[0].IDs[0] != "99" || !errors.Is(quotes.ChunkErrors[0], ErrInvalidID) {
+		t.Errorf("Expected one ErrInvalidID error for 99, got %v", quotes.ChunkErrors)
+	}
+	var apiErr *APIError
+	if !errors.As(quotes.ChunkErrors[0], &apiErr) || apiErr.Code != binanceInvalidSymbol {
+		t.Errorf("Expected Binance error code %d, got %v", binanceInvalidSymbol, quotes.ChunkErrors[0])
+	}
+}
+
+// TestBinanceRateLimited tests that 429 and 418 responses map to ErrRateLimited
+func TestBinanceRateLimited(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusTeapot} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":-1003,"msg":"Too much request weight used."}`))
+		}))
+		provider := NewBinanceProvider(config.BinanceSettings{
+			BaseURL: server.URL, Symbols: map[int]string{1: "BTC"}, QuoteAssets: []string{"USDT"},
+		}, server.Client(), testLogger())
+
+		if _, err := provider.FetchQuotes(context.Background(), []string{"1"}); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("status %d: expected ErrRateLimited, got %v", status, err)
+		}
+		server.Close()
+	}
+}
+
+// TestBinanceErrorBody tests that error bodies without a Binance error code are truncated to maxErrorBody on a rune
+// boundary
+func TestBinanceErrorBody(t *testing.T) {
+	body := "<html>" + strings.Repeat("x", 2*maxErrorBody) + "</html>"
+	// Two byte runes after one byte so byte maxErrorBody falls inside a rune
+	multiByte := "x" + strings.Repeat("é", maxErrorBody)
+	tests := []struct {
+		name string
+		resp []byte
+		want int
+	}{
+		{"not JSON", []byte(body), maxErrorBody},
+		{"long message", []byte(`{"code":-1000,"msg":"` + body + `"}`), maxErrorBody},
+		{"multi-byte", []byte(multiByte), maxErrorBody - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := binanceError(http.StatusBadGateway, tt.resp)
+			if len(apiErr.Message) != tt.want || !errors.Is(apiErr, ErrAPI) {
+				t.Errorf("Expected an ErrAPI message of %d bytes, got %d bytes: %v", tt.want, len(apiErr.Message), apiErr)
+			}
+			if !utf8.ValidString(apiErr.Message) {
+				t.Errorf("Expected valid UTF-8, got %q", apiErr.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package ticker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// CMC charges 1 credit per 100 coins returned (rounded up) for each convert target.
+// creditBudget tracks the credits reported by upstream over a rolling window so on-demand refreshes can be refused
+// before they exceed the configured budget (CMC_CREDIT_BUDGET per CMC_CREDIT_WINDOW).
+
+// ErrCreditBudgetExceeded is returned when a call would exceed the configured credit budget.
+var ErrCreditBudgetExceeded = errors.New("credit budget exceeded")
+
+// creditsPerCoins is the number of coins covered by one credit per convert target
+const creditsPerCoins = 100
+
+// creditSpend is the number of credits used (or reserved) at a point in time
+type creditSpend struct {
+	at      time.Time
+	credits int
+}
+
+// creditBudget holds the credits spent within the rolling window. Safe for concurrent use.
+type creditBudget struct {
+	mu     sync.Mutex
+	limit  int // 0 disables the budget
+	window time.Duration
+	spent  []*creditSpend
+}
+
+// newCreditBudget creates a new credit budget of limit credits per window
+func newCreditBudget(limit int, window time.Duration) *creditBudget {
+	return &creditBudget{limit: limit, window: window}
+}
+
+// record adds credits spent at now
+func (b *creditBudget) record(now time.Time, credits int) {
+	if credits <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent = append(b.spent, &creditSpend{at: now, credits: credits})
+}
+
+// used returns the credits spent within the window ending at now and drops older entries
+func (b *creditBudget) used(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.usedLocked(now)
+}
+
+// usedLocked is used with mu held
+func (b *creditBudget) usedLocked(now time.Time) int {
+	cutoff := now.Add(-b.window)
+	kept := b.spent[:0]
+	total := 0
+	for _, s := range b.spent {
+		if s.at.After(cutoff) {
+			kept = append(kept, s)
+			total += s.credits
+		}
+	}
+	b.spent = kept
+	return total
+}
+
+// allow returns ErrCreditBudgetExceeded if spending credits at now would exceed the budget
+func (b *creditBudget) allow(now time.Time, credits int) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	if b.used(now)+credits > b.limit {
+		return ErrCreditBudgetExceeded
+	}
+	return nil
+}
+
+// reserve checks the budget and holds credits at now in one step, so concurrent callers cannot both pass the check
+// and exceed the budget together. Returns ErrCreditBudgetExceeded without reserving if credits do not fit.
+// The reservation counts against the budget until settle replaces it with the credits actually spent.
+func (b *creditBudget) reserve(now time.Time, credits int) (*creditSpend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && b.usedLocked(now)+credits > b.limit {
+		return nil, ErrCreditBudgetExceeded
+	}
+	spend := &creditSpend{at: now, credits: credits}
+	b.spent = append(b.spent, spend)
+	return spend, nil
+}
+
+// settle replaces the credits held by a reservation with the credits reported by upstream
+func (b *creditBudget) settle(spend *creditSpend, credits int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	spend.credits = max(credits, 0)
+}
+
+// estimateCredits returns the credits CMC charges for fetching the jobs
+func estimateCredits(jobs []fetchJob) int {
+	credits := 0
+	for _, job := range jobs {
+		perTarget := (len(job.ids) + creditsPerCoins - 1) / creditsPerCoins
+		credits += perTarget * max(len(job.convert.values), 1)
+	}
+	return credits
+}
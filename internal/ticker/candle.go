@@ -0,0 +1,209 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Candles are aggregated from stored quote snapshots instead of CMC's OHLCV endpoints (extra credits).
+// Every new coin_quote_history row updates the 5m, 1h and 1d candle it falls into (incremental, same transaction).
+// RebuildCandles recomputes candles for a time range from coin_quote_history.
+// Snapshots only carry the rolling volume_24h, not the volume traded during an interval. Candles store the volume_24h
+// of the last snapshot in the bucket as volume_24h_at_close: it is not the candle volume and must not be summed across
+// candles (see migrations/ticker/006_create_coin_candle_table).
+
+// Resolution is a candle bucket size
+type Resolution struct {
+	Name     string // stored in coin_candle.resolution
+	Duration time.Duration
+}
+
+// CandleResolutions are the resolutions maintained in coin_candle
+var CandleResolutions = []Resolution{
+	{Name: "5m", Duration: 5 * time.Minute},
+	{Name: "1h", Duration: time.Hour},
+	{Name: "1d", Duration: 24 * time.Hour},
+}
+
+// Candle holds one OHLC candle and the 24h volume at its close. Maps to a coin_candle row.
+type Candle struct {
+	CoinID           int // coin_info.id
+	Currency         string
+	Resolution       string
+	BucketStart      time.Time
+	Open             Decimal
+	High             Decimal
+	Low              Decimal
+	Close            Decimal
+	Volume24HAtClose Decimal   // rolling 24h volume of the last snapshot, not the volume of the interval
+	OpenTime         time.Time // timestamp of the first snapshot in the bucket
+	CloseTime        time.Time // timestamp of the last snapshot in the bucket
+	SampleCount      int
+}
+
+// candlePoint is a single quote snapshot used to build candles
+type candlePoint struct {
+	coinID    int
+	currency  string
+	price     Decimal
+	volume24h Decimal // rolling 24h volume
+	time      time.Time
+}
+
+const (
+	// upsertCandleSQL merges one snapshot into its candle. All SET expressions read the row values before the update.
+	upsertCandleSQL = `
+INSERT INTO coin_candle (coin_id, currency, resolution, bucket_start, open, high, low, close, volume_24h_at_close, open_time, close_time, sample_count)
+VALUES ($1, $2, $3, $4, $5, $5, $5, $5, $6, $7, $7, 1)
+ON CONFLICT ON CONSTRAINT unique_coin_candle DO UPDATE SET
+    open = CASE WHEN EXCLUDED.open_time < coin_candle.open_time THEN EXCLUDED.open ELSE coin_candle.open END,
+    high = GREATEST(coin_candle.high, EXCLUDED.high),
+    low = LEAST(coin_candle.low, EXCLUDED.low),
+    close = CASE WHEN EXCLUDED.close_time >= coin_candle.close_time THEN EXCLUDED.close ELSE coin_candle.close END,
+    volume_24h_at_close = CASE WHEN EXCLUDED.close_time >= coin_candle.close_time
+        THEN EXCLUDED.volume_24h_at_close ELSE coin_candle.volume_24h_at_close END,
+    open_time = LEAST(coin_candle.open_time, EXCLUDED.open_time),
+    close_time = GREATEST(coin_candle.close_time, EXCLUDED.close_time),
+    sample_count = coin_candle.sample_count + 1,
+    updated_at = CURRENT_TIMESTAMP`
+
+	deleteCandlesSQL = `
+DELETE FROM coin_candle WHERE resolution = $1 AND bucket_start >= $2 AND bucket_start < $3`
+
+	selectHistoryRangeSQL = `
+SELECT coin_id, currency, price, volume_24h, last_updated
+FROM coin_quote_history
+WHERE last_updated >= $1 AND last_updated < $2
+ORDER BY coin_id, currency, last_updated`
+
+	insertCandleSQL = `
+INSERT INTO coin_candle (coin_id, currency, resolution, bucket_start, open, high, low, close, volume_24h_at_close, open_time, close_time, sample_count)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+)
+
+// bucketStart returns the start of the bucket containing ts. Buckets are aligned to UTC.
+func bucketStart(ts time.Time, d time.Duration) time.Time {
+	return ts.UTC().Truncate(d)
+}
+
+// updateCandles merges one new snapshot into its candle for every resolution
+func updateCandles(ctx context.Context, tx *sql.Tx, p candlePoint) error {
+	for _, res := range CandleResolutions {
+		_, err := tx.ExecContext(ctx, upsertCandleSQL,
+			p.coinID, p.currency, res.Name, bucketStart(p.time, res.Duration), p.price, p.volume24h, p.time.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update %s candle: %w", res.Name, err)
+		}
+	}
+	return nil
+}
+
+// aggregateCandles builds candles at resolution res from points sorted by coin, currency and time.
+func aggregateCandles(points []candlePoint, res Resolution) []Candle {
+	var candles []Candle
+	for _, p := range points {
+		start := bucketStart(p.time, res.Duration)
+		if n := len(candles); n > 0 {
+			c := &candles[n-1]
+			if c.CoinID == p.coinID && c.Currency == p.currency && c.BucketStart.Equal(start) {
+				if p.price.Cmp(c.High) > 0 {
+					c.High = p.price
+				}
+				if p.price.Cmp(c.Low) < 0 {
+					c.Low = p.price
+				}
+				c.Close = p.price
+				c.Volume24HAtClose = p.volume24h
+				c.CloseTime = p.time
+				c.SampleCount++
+				continue
+			}
+		}
+		candles = append(candles, Candle{
+			CoinID: p.coinID, Currency: p.currency, Resolution: res.Name, BucketStart: start,
+			Open: p.price, High: p.price, Low: p.price, Close: p.price, Volume24HAtClose: p.volume24h,
+			OpenTime: p.time, CloseTime: p.time, SampleCount: 1,
+		})
+	}
+	return candles
+}
+
+// RebuildCandles recomputes every candle overlapping [from, to) from coin_quote_history in a single transaction.
+// The range is widened to whole buckets for each resolution so partially covered candles are rebuilt completely.
+func (t *TickerService) RebuildCandles(ctx context.Context, from, to time.Time) (err error) {
+	sqlDB := t.database()
+	if sqlDB == nil {
+		return fmt.Errorf("rebuild candles: database not connected")
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("rebuild candles: invalid range %v - %v", from, to)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, res := range CandleResolutions {
+		start := bucketStart(from, res.Duration)
+		end := bucketStart(to, res.Duration)
+		if end.Before(to.UTC()) {
+			end = end.Add(res.Duration)
+		}
+
+		points, err := loadCandlePoints(ctx, tx, start, end)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, deleteCandlesSQL, res.Name, start, end); err != nil {
+			return fmt.Errorf("failed to delete %s candles: %w", res.Name, err)
+		}
+		candles := aggregateCandles(points, res)
+		for _, c := range candles {
+			_, err = tx.ExecContext(ctx, insertCandleSQL,
+				c.CoinID, c.Currency, c.Resolution, c.BucketStart, c.Open, c.High, c.Low, c.Close, c.Volume24HAtClose,
+				c.OpenTime, c.CloseTime, c.SampleCount,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert %s candle: %w", res.Name, err)
+			}
+		}
+		t.logger.Info("Rebuilt candles", "resolution", res.Name, "from", start, "to", end, "candles_count", len(candles))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// loadCandlePoints returns the history snapshots in [from, to) sorted by coin, currency and time
+func loadCandlePoints(ctx context.Context, tx *sql.Tx, from, to time.Time) ([]candlePoint, error) {
+	rows, err := tx.QueryContext(ctx, selectHistoryRangeSQL, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quote history: %w", err)
+	}
+	defer rows.Close()
+
+	var points []candlePoint
+	for rows.Next() {
+		var p candlePoint
+		if err := rows.Scan(&p.coinID, &p.currency, &p.price, &p.volume24h, &p.time); err != nil {
+			return nil, fmt.Errorf("failed to scan quote history: %w", err)
+		}
+		p.time = p.time.UTC()
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read quote history: %w", err)
+	}
+	return points, nil
+}
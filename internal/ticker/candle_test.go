@@ -0,0 +1,59 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAggregateCandles tests OHLCV aggregation of snapshots into buckets per coin and currency
+func TestAggregateCandles(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []candlePoint{
+		{coinID: 1, currency: "USD", price: "100", volume24h: "10", time: base.Add(1 * time.Minute)},
+		{coinID: 1, currency: "USD", price: "120", volume24h: "11", time: base.Add(2 * time.Minute)},
+		{coinID: 1, currency: "USD", price: "90", volume24h: "12", time: base.Add(3 * time.Minute)},
+		{coinID: 1, currency: "USD", price: "95", volume24h: "13", time: base.Add(6 * time.Minute)},
+		{coinID: 2, currency: "USD", price: "5", volume24h: "1", time: base.Add(4 * time.Minute)},
+	}
+
+	t.Run("5m", func(t *testing.T) {
+		candles := aggregateCandles(points, Resolution{Name: "5m", Duration: 5 * time.Minute})
+		if len(candles) != 3 {
+			t.Fatalf("Expected 3 candles, got %d", len(candles))
+		}
+		first := candles[0]
+		if first.Open != "100" || first.High != "120" || first.Low != "90" || first.Close != "90" || first.Volume24HAtClose != "12" {
+			t.Errorf("Unexpected OHLCV for first candle: %+v", first)
+		}
+		if first.SampleCount != 3 || !first.BucketStart.Equal(base) {
+			t.Errorf("Expected 3 samples in bucket %v, got %d in %v", base, first.SampleCount, first.BucketStart)
+		}
+		if second := candles[1]; second.Open != "95" || !second.BucketStart.Equal(base.Add(5*time.Minute)) {
+			t.Errorf("Unexpected second candle: %+v", second)
+		}
+		if third := candles[2]; third.CoinID != 2 || third.Close != "5" {
+			t.Errorf("Expected separate candle for coin 2, got %+v", third)
+		}
+	})
+
+	t.Run("1h", func(t *testing.T) {
+		candles := aggregateCandles(points, Resolution{Name: "1h", Duration: time.Hour})
+		if len(candles) != 2 {
+			t.Fatalf("Expected 2 candles, got %d", len(candles))
+		}
+		if c := candles[0]; c.Open != "100" || c.High != "120" || c.Low != "90" || c.Close != "95" || c.SampleCount != 4 {
+			t.Errorf("Unexpected OHLCV for 1h candle: %+v", c)
+		}
+	})
+}
+
+// TestBucketStart tests that buckets are aligned to UTC
+func TestBucketStart(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 13, 47, 12, 0, time.FixedZone("EST", -5*3600))
+	if got, want := bucketStart(ts, 24*time.Hour), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("1d bucket = %v, want %v", got, want)
+	}
+	if got, want := bucketStart(ts, 5*time.Minute), time.Date(2024, 1, 1, 18, 45, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("5m bucket = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package ticker
+
+// Coinmarketcap (CMC) API Documentation: https://coinmarketcap.com/api/documentation/v1/
+// CMC recommends using CoinMarketCap ID's instead of ID or other identifiers
+// Common endpoints:
+// https://pro-api.coinmarketcap.com/v1/cryptocurrency/listings/latest
+// https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest
+
+// Sample CMD ID's:
+// Bitcoin CMC ID: 1
+// Ethereum CMC ID: 1027
+// Solana CMC ID: 5994
+// Sui CMC ID: 20947
+// Cardano CMC ID: 2010
+// ICP: 8916
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// CMCProvider implements QuoteProvider and ListingsProvider with the CMC quotes and listings endpoints.
+type CMCProvider struct {
+	rest      *restClient
+	quotesURL string
+	listings  listingsParams // listings mode query
+	convert   []convertParam // convert targets grouped per request
+	aux       []string       // aux fields requested from the quotes endpoint
+	chunkSize int            // max ID's per request
+	workers   int            // max concurrent requests per sync
+	logger    *slog.Logger
+}
+
+// NewCMCProvider creates a new instance of the CMCProvider struct
+func NewCMCProvider(cmc config.CMCSettings, client *http.Client, logger *slog.Logger) *CMCProvider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cmc.QuotesURL == "" {
+		logger.Warn("No quotes URL provided - requires quotes URL")
+	}
+	return &CMCProvider{
+		rest:      newCMCClient(cmc.APIKey, client, logger),
+		quotesURL: cmc.QuotesURL,
+		listings: listingsParams{
+			url:   cmc.ListingsURL,
+			start: cmc.ListingsStart,
+			limit: cmc.ListingsLimit,
+			sort:  cmc.ListingsSort,
+		},
+		convert:   buildConvertGroups(cmc.Convert, cmc.ConvertIDs, cmc.MaxConvertPerCall),
+		aux:       cmc.Aux,
+		chunkSize: cmc.IDChunkSize,
+		workers:   cmc.MaxConcurrency,
+		logger:    logger,
+	}
+}
+
+// Name returns the provider name
+func (c *CMCProvider) Name() string {
+	return ProviderCMC
+}
+
+// FetchQuotes fetches the quotes of ids in chunks of ID's per convert group (see fetch.go)
+func (c *CMCProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	resp, chunkErrs, err := c.fetchQuotes(ctx, ids)
+	if err != nil {
+		return &ProviderQuotes{Provider: ProviderCMC, ChunkErrors: chunkErrs}, err
+	}
+	quotes := providerQuotes(resp)
+	quotes.ChunkErrors = chunkErrs
+	return quotes, nil
+}
+
+// FetchListings fetches the configured listings (see listings.go)
+func (c *CMCProvider) FetchListings(ctx context.Context) (*ProviderQuotes, []string, error) {
+	resp, ids, err := c.fetchListings(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return providerQuotes(resp), ids, nil
+}
+
+// EstimateCredits returns the credits CMC charges for fetching the quotes of ids
+func (c *CMCProvider) EstimateCredits(ids []string) int {
+	return estimateCredits(buildFetchJobs(ids, c.convert, c.chunkSize))
+}
+
+// providerQuotes converts a merged CMC response into ProviderQuotes
+func providerQuotes(resp *CMCResponse) *ProviderQuotes {
+	return &ProviderQuotes{
+		Provider:    ProviderCMC,
+		Coins:       resp.Data,
+		CreditCount: resp.Status.CreditCount,
+		Elapsed:     time.Duration(resp.Status.Elapsed) * time.Millisecond,
+	}
+}
+
+// CallAPI gets data from CMC for one chunk of ID's and one group of convert targets and returns a []byte of the JSON response
+func (c *CMCProvider) CallAPI(ctx context.Context, ids []string, convert convertParam) ([]byte, error) {
+	// Build query parameters
+	q := url.Values{}
+
+	// Collect all IDs in the chunk
+	q.Add("id", strings.Join(ids, ",")) // Join IDs with commas and add to query
+	q.Add(convert.key, convert.String())
+	q.Add("skip_invalid", "true") // invalid ID's are left out of the response and reported as missing
+
+	// Only get requested fields (automatically get price, market_cap, volume_24h, etc. in "quotes"):
+	// Available aux fields: num_market_pairs, cmc_rank, date_added, tags, platform, max_supply,
+	// circulating_supply, total_supply, market_cap_by_total_supply, volume_24h_reported,
+	// volume_7d, volume_7d_reported, volume_30d, volume_30d_reported, is_active, is_fiat
+	// Configured in CMC_AUX. Fields not requested are stored as NULL.
+	if len(c.aux) > 0 {
+		q.Add("aux", strings.Join(c.aux, ","))
+	}
+
+	return c.rest.get(ctx, c.quotesURL, q)
+}
+
+// DecodeData decodes a JSON []byte into a CMCResponse struct and checks the response status for API errors.
+// API errors are returned as *APIError and can be matched with errors.Is() (ErrUnauthorized, ErrRateLimited, etc.)
+func (c *CMCProvider) DecodeData(data []byte) (*CMCResponse, error) {
+	// Unmarshal JSON response into CMCResponse struct
+	var cmcResponse CMCResponse
+	if err := json.Unmarshal(data, &cmcResponse); err != nil {
+		c.logger.Error("failed to unmarshal response", "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	// Check for API errors
+	if err := checkStatus(cmcResponse.Status); err != nil {
+		c.logger.Error("Coinmarketcap API returned error", "error", err, "credit_count", cmcResponse.Status.CreditCount)
+		return nil, err
+	}
+
+	c.logger.Info("Successfully decoded CMC data",
+		"coins_count", len(cmcResponse.Data),
+		"credit_count", cmcResponse.Status.CreditCount)
+	return &cmcResponse, nil
+}
+
+// checkStatus returns the API error reported in the status of a CMC response as *APIError, nil if there is none
+func checkStatus(status Status) error {
+	if status.ErrorCode == 0 {
+		return nil
+	}
+	errorMsg := "API error"
+	if status.ErrorMessage != nil {
+		errorMsg = *status.ErrorMessage
+	}
+	return newAPIError(status.ErrorCode, errorMsg)
+}
+
+// newCMCClient creates the restClient shared by every CMC endpoint. CMC endpoints are configured as full URLs
+// (CMC_*_URL), so the client has no base URL and the endpoint URL is passed as path.
+func newCMCClient(apiKey string, client *http.Client, logger *slog.Logger) *restClient {
+	header := http.Header{}
+	header.Set("X-CMC_PRO_API_KEY", apiKey)
+	return &restClient{
+		provider: ProviderCMC,
+		header:   header,
+		apiError: cmcError,
+		limiter:  newRateLimiter(0, time.Second),
+		client:   client,
+		logger:   logger,
+	}
+}
+
+// cmcError maps a CMC error response to an *APIError. CMC reports errors in the status object of the body
+// (see newAPIError), responses without one are mapped by HTTP status code.
+func cmcError(status int, body []byte) *APIError {
+	var resp struct {
+		Status Status `json:"status"`
+	}
+	var apiErr *APIError
+	if err := json.Unmarshal(body, &resp); err == nil && errors.As(checkStatus(resp.Status), &apiErr) {
+		return apiErr
+	}
+	return newHTTPError(ProviderCMC, status, errorMessage(string(body)))
+}
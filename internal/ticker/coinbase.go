@@ -0,0 +1,200 @@
+package ticker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// Coinbase Exchange API documentation: https://docs.cdp.coinbase.com/exchange/reference/exchangerestapi_getproductticker
+// TICKER_PROVIDER=coinbase syncs exchange prices from the Coinbase Exchange public product endpoints. Tracked coins are
+// mapped to Coinbase base currencies in COINBASE_CURRENCIES (cmc_id:currency) and paired with every quote currency in
+// COINBASE_QUOTE_CURRENCIES into product ID's (ex. BTC + USD -> BTC-USD). Quotes are stored under the quote currency.
+// Coinbase has no batch endpoint, every product costs two calls:
+// /products/{id}/ticker returns the last price, the 24h volume in the base currency and the trade time.
+// /products/{id}/stats returns the 24h open used to compute the 24h change.
+// The 24h volume is converted to the quote currency with the last price. Public endpoints allow 10 calls per second
+// per IP, calls are spaced to stay under COINBASE_RATE_LIMIT calls per second. Results are quotes only.
+
+// coinbaseProduct is a product of a tracked coin
+type coinbaseProduct struct {
+	cmcID string
+	base  string
+	quote string // quote currency, stored as currency
+}
+
+// CoinbaseProvider implements QuoteProvider with the Coinbase Exchange product ticker and stats endpoints.
+type CoinbaseProvider struct {
+	rest            *restClient
+	currencies      map[string]string // CMC ID -> base currency
+	quoteCurrencies []string
+	logger          *slog.Logger
+}
+
+// coinbaseTicker holds the /products/{id}/ticker response. Numbers are JSON strings.
+type coinbaseTicker struct {
+	Price  Decimal `json:"price"`
+	Volume Decimal `json:"volume"` // 24h volume in the base currency
+	Time   CMCTime `json:"time"`
+}
+
+// coinbaseStats holds the /products/{id}/stats response
+type coinbaseStats struct {
+	Open Decimal `json:"open"` // price 24h ago
+}
+
+// coinbaseErrorResponse holds a Coinbase error response
+type coinbaseErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// NewCoinbaseProvider creates a new instance of the CoinbaseProvider struct
+func NewCoinbaseProvider(cb config.CoinbaseSettings, client *http.Client, logger *slog.Logger) *CoinbaseProvider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if len(cb.Currencies) == 0 {
+		logger.Warn("No Coinbase currencies provided - requires COINBASE_CURRENCIES to map tracked coins")
+	}
+	quoteCurrencies := make([]string, 0, len(cb.QuoteCurrencies))
+	for _, q := range cb.QuoteCurrencies {
+		quoteCurrencies = append(quoteCurrencies, strings.ToUpper(q))
+	}
+	if len(quoteCurrencies) == 0 {
+		quoteCurrencies = []string{"USD"}
+	}
+	currencies := providerIDs(cb.Currencies)
+	for id, base := range currencies {
+		currencies[id] = strings.ToUpper(base)
+	}
+	return &CoinbaseProvider{
+		rest: &restClient{
+			provider: ProviderCoinbase,
+			baseURL:  cb.BaseURL,
+			apiError: coinbaseError,
+			limiter:  newRateLimiter(cb.RateLimit, time.Second),
+			client:   client,
+			logger:   logger,
+		},
+		currencies:      currencies,
+		quoteCurrencies: quoteCurrencies,
+		logger:          logger,
+	}
+}
+
+// Name returns the provider name
+func (c *CoinbaseProvider) Name() string {
+	return ProviderCoinbase
+}
+
+// EstimateCredits returns 0, Coinbase calls are rate limited instead
+func (c *CoinbaseProvider) EstimateCredits(ids []string) int {
+	return 0
+}
+
+// FetchQuotes fetches the ticker and stats of every product of the mapped coins. A failing product is reported in
+// ChunkErrors and does not stop the other products unless the error applies to every call.
+func (c *CoinbaseProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	products := make(map[string]coinbaseProduct)
+	var unmapped []string
+	for _, id := range ids {
+		base, ok := c.currencies[id]
+		if !ok {
+			unmapped = append(unmapped, id)
+			continue
+		}
+		for _, quote := range c.quoteCurrencies {
+			products[base+"-"+quote] = coinbaseProduct{cmcID: id, base: base, quote: quote}
+		}
+	}
+	quotes := &ProviderQuotes{Provider: ProviderCoinbase, Coins: make(map[string]CoinInfo), QuotesOnly: true}
+	if len(unmapped) > 0 {
+		quotes.ChunkErrors = append(quotes.ChunkErrors, ChunkError{IDs: unmapped, Err: ErrUnmappedCoin})
+	}
+
+	productIDs := sortedKeys(products)
+	var firstErr error
+	failed := 0
+	for _, productID := range productIDs {
+		product := products[productID]
+		var err error
+		if firstErr != nil && isFatalAPIError(firstErr) {
+			err = firstErr // remaining calls would fail with the same error
+		} else {
+			err = c.fetchProduct(ctx, productID, product, quotes.Coins)
+		}
+		if err == nil {
+			continue
+		}
+		failed++
+		if firstErr == nil || isFatalAPIError(err) {
+			firstErr = err
+		}
+		quotes.ChunkErrors = append(quotes.ChunkErrors, ChunkError{IDs: []string{product.cmcID}, Convert: product.quote, Err: err})
+	}
+	if len(productIDs) > 0 && failed == len(productIDs) {
+		quotes.Coins = nil
+		return quotes, firstErr
+	}
+
+	c.logger.Info("Fetched quotes", "products", len(productIDs), "failed_products", failed,
+		"coins_count", len(quotes.Coins), "unmapped_count", len(unmapped))
+	return quotes, nil
+}
+
+// fetchProduct calls the ticker and stats endpoints of one product and adds its quote to coins
+func (c *CoinbaseProvider) fetchProduct(ctx context.Context, productID string, product coinbaseProduct, coins map[string]CoinInfo) error {
+	var ticker coinbaseTicker
+	if err := c.getJSON(ctx, "/products/"+url.PathEscape(productID)+"/ticker", &ticker); err != nil {
+		return err
+	}
+	var stats coinbaseStats
+	if err := c.getJSON(ctx, "/products/"+url.PathEscape(productID)+"/stats", &stats); err != nil {
+		return err
+	}
+
+	coin := coins[product.cmcID]
+	if coin.Quote == nil {
+		cmcID, _ := strconv.Atoi(product.cmcID)
+		coin = CoinInfo{CmcID: cmcID, Symbol: product.base, Quote: make(map[string]CoinQuote)}
+	}
+	coin.Quote[product.quote] = CoinQuote{
+		Price:            ticker.Price,
+		Volume24H:        ticker.Volume.Mul(ticker.Price, amountNumeric.scale),
+		PercentChange24h: percentChange(stats.Open, ticker.Price),
+		LastUpdated:      ticker.Time,
+	}
+	coins[product.cmcID] = coin
+	return nil
+}
+
+// getJSON calls path and unmarshals the response into v
+func (c *CoinbaseProvider) getJSON(ctx context.Context, path string, v any) error {
+	data, err := c.rest.get(ctx, path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		c.logger.Error("failed to unmarshal response", "error", err, "path", path)
+		return decodeError(ProviderCoinbase, err)
+	}
+	return nil
+}
+
+// coinbaseError maps a Coinbase error response ({"message":"NotFound"}) to an *APIError by HTTP status code.
+// Unknown products return 404.
+func coinbaseError(status int, body []byte) *APIError {
+	var resp coinbaseErrorResponse
+	msg := string(body)
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Message != "" {
+		msg = resp.Message
+	}
+	return newHTTPError(ProviderCoinbase, status, errorMessage(msg))
+}
@@ -0,0 +1,78 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestCoinbaseProducts tests mapping of product ticker and stats into quotes and unknown products
+func TestCoinbaseProducts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products/BTC-USD/ticker":
+			w.Write([]byte(`{"ask":"67187.34","bid":"67187.32","volume":"12.5","trade_id":1,"price":"67187.33","size":"0.01",
+				"time":"2024-03-25T08:05:09.426523Z"}`))
+		case "/products/BTC-USD/stats":
+			w.Write([]byte(`{"open":"64000","high":"67500","low":"63900","last":"67187.33","volume":"12.5"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"NotFound"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewCoinbaseProvider(config.CoinbaseSettings{
+		BaseURL: server.URL, Currencies: map[int]string{1: "btc", 99: "FOO"}, QuoteCurrencies: []string{"usd"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "99", "1027"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !quotes.QuotesOnly {
+		t.Error("Expected quotes only result")
+	}
+	btc := quotes.Coins["1"]
+	usd := btc.Quote["USD"]
+	if btc.Symbol != "BTC" || usd.Price != "67187.33" || usd.Volume24H != "839841.62500000" {
+		t.Errorf("Expected BTC-USD price and volume in USD, got %+v", btc)
+	}
+	if usd.PercentChange24h != "4.98020313" || usd.LastUpdated.IsZero() {
+		t.Errorf("Expected 24h change from the open and trade time, got %+v", usd)
+	}
+	if len(quotes.ChunkErrors) != 2 || !errors.Is(quotes.ChunkErrors[0], ErrUnmappedCoin) {
+		t.Fatalf("Expected 1027 unmapped and 99 invalid, got %v", quotes.ChunkErrors)
+	}
+	if ce := quotes.ChunkErrors[1]; ce.IDs[0] != "99" || ce.Convert != "USD" || !errors.Is(ce, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for FOO-USD, got %v", ce)
+	}
+}
+
+// TestCoinbaseRateLimited tests that a rate limit stops the remaining products
+func TestCoinbaseRateLimited(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message":"Public rate limit exceeded"}`))
+	}))
+	defer server.Close()
+
+	provider := NewCoinbaseProvider(config.CoinbaseSettings{
+		BaseURL: server.URL, Currencies: map[int]string{1: "BTC", 1027: "ETH"}, QuoteCurrencies: []string{"USD", "EUR"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "1027"})
+	var apiErr *APIError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.Message != "Public rate limit exceeded" {
+		t.Fatalf("Expected ErrRateLimited with the Coinbase message, got %v", err)
+	}
+	if calls != 1 || len(quotes.ChunkErrors) != 4 {
+		t.Errorf("Expected 1 call and 4 failed products, got %d calls and %v", calls, quotes.ChunkErrors)
+	}
+}
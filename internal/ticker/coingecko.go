@@ -0,0 +1,282 @@
+package ticker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// CoinGecko API documentation: https://docs.coingecko.com/reference/introduction
+// TICKER_PROVIDER=coingecko syncs quotes from CoinGecko instead of CMC. Tracked coins are mapped to CoinGecko ID's in
+// COINGECKO_IDS (cmc_id:coingecko_id), unmapped coins are reported as missing with ErrUnmappedCoin.
+// COINGECKO_ENDPOINT selects the endpoint:
+// "markets" (/coins/markets) returns the name, symbol, supplies and quotes of one currency per call. CMC only coin
+// fields are left unchanged (ProviderQuotes.MarketFields), CoinGecko's market_cap_rank is not a CMC rank and is dropped.
+// "simple" (/simple/price) returns price, market cap, 24h volume and 24h change of every currency in one call and
+// has no coin fields (ProviderQuotes.QuotesOnly). Coins are skipped with ErrNoCoinInfo until a sync with coin fields
+// (CMC or the markets endpoint) has created their coin_info row.
+// CoinGecko has no credits, calls are spaced to stay under COINGECKO_RATE_LIMIT calls per minute instead.
+
+// CoinGecko endpoints selectable in COINGECKO_ENDPOINT
+const (
+	CoinGeckoMarkets = "markets"
+	CoinGeckoSimple  = "simple"
+)
+
+// coinGeckoMaxIDs is the max number of coins per call (per_page limit of /coins/markets)
+const coinGeckoMaxIDs = 250
+
+// CoinGeckoProvider implements QuoteProvider with the CoinGecko markets or simple price endpoint.
+type CoinGeckoProvider struct {
+	rest       *restClient
+	endpoint   string            // CoinGeckoMarkets or CoinGeckoSimple
+	currencies []string          // vs_currencies, lowercase
+	ids        map[string]string // CMC ID -> CoinGecko ID
+	logger     *slog.Logger
+}
+
+// coinGeckoMarket holds one coin from /coins/markets. Prices and changes are in the requested vs_currency.
+type coinGeckoMarket struct {
+	ID                    string   `json:"id"`
+	Symbol                string   `json:"symbol"`
+	Name                  string   `json:"name"`
+	CurrentPrice          Decimal  `json:"current_price"`
+	MarketCap             Decimal  `json:"market_cap"`
+	FullyDilutedValuation Decimal  `json:"fully_diluted_valuation"`
+	TotalVolume           Decimal  `json:"total_volume"`
+	CirculatingSupply     Decimal  `json:"circulating_supply"`
+	TotalSupply           Decimal  `json:"total_supply"`
+	MaxSupply             *Decimal `json:"max_supply"`
+	PercentChange1H       Decimal  `json:"price_change_percentage_1h_in_currency"`
+	PercentChange24H      Decimal  `json:"price_change_percentage_24h_in_currency"`
+	PercentChange7D       Decimal  `json:"price_change_percentage_7d_in_currency"`
+	PercentChange30D      Decimal  `json:"price_change_percentage_30d_in_currency"`
+	LastUpdated           CMCTime  `json:"last_updated"`
+}
+
+// coinGeckoPrices holds the /simple/price response: CoinGecko ID -> field -> value. Fields are the currency ("usd"),
+// the currency with a suffix ("usd_market_cap", "usd_24h_vol", "usd_24h_change") and "last_updated_at" (unix seconds).
+type coinGeckoPrices map[string]map[string]Decimal
+
+// NewCoinGeckoProvider creates a new instance of the CoinGeckoProvider struct
+func NewCoinGeckoProvider(cg config.CoinGeckoSettings, client *http.Client, logger *slog.Logger) *CoinGeckoProvider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	endpoint := cg.Endpoint
+	if endpoint != CoinGeckoMarkets && endpoint != CoinGeckoSimple {
+		logger.Warn("Unknown CoinGecko endpoint - using markets", "endpoint", endpoint)
+		endpoint = CoinGeckoMarkets
+	}
+	if len(cg.IDs) == 0 {
+		logger.Warn("No CoinGecko ID's provided - requires COINGECKO_IDS to map tracked coins")
+	}
+	currencies := make([]string, 0, len(cg.VsCurrencies))
+	for _, c := range cg.VsCurrencies {
+		currencies = append(currencies, strings.ToLower(c))
+	}
+	if len(currencies) == 0 {
+		currencies = []string{"usd"}
+	}
+	header := http.Header{}
+	if cg.APIKey != "" {
+		header.Set(cg.APIKeyHeader, cg.APIKey)
+	}
+	return &CoinGeckoProvider{
+		rest: &restClient{
+			provider: ProviderCoinGecko,
+			baseURL:  cg.BaseURL,
+			header:   header,
+			limiter:  newRateLimiter(cg.RateLimit, time.Minute),
+			client:   client,
+			logger:   logger,
+		},
+		endpoint:   endpoint,
+		currencies: currencies,
+		ids:        providerIDs(cg.IDs),
+		logger:     logger,
+	}
+}
+
+// Name returns the provider name
+func (g *CoinGeckoProvider) Name() string {
+	return ProviderCoinGecko
+}
+
+// EstimateCredits returns 0, CoinGecko calls are rate limited instead
+func (g *CoinGeckoProvider) EstimateCredits(ids []string) int {
+	return 0
+}
+
+// FetchQuotes fetches the quotes of the mapped coins in chunks of coinGeckoMaxIDs. The markets endpoint is called once
+// per chunk per currency, the simple endpoint once per chunk. A failing call is reported in ChunkErrors and does not
+// stop the other calls unless the error applies to every call (unauthorized, rate limited, plan limit).
+func (g *CoinGeckoProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	byGeckoID, unmapped := mapIDs(g.ids, ids)
+	quotes := &ProviderQuotes{
+		Provider:     ProviderCoinGecko,
+		Coins:        make(map[string]CoinInfo),
+		QuotesOnly:   g.endpoint == CoinGeckoSimple,
+		MarketFields: g.endpoint == CoinGeckoMarkets,
+	}
+	if len(unmapped) > 0 {
+		quotes.ChunkErrors = append(quotes.ChunkErrors, ChunkError{IDs: unmapped, Err: ErrUnmappedCoin})
+	}
+
+	// One call per chunk for the simple endpoint, one per chunk and currency for markets
+	type call struct {
+		geckoIDs   []string
+		currencies []string
+	}
+	var calls []call
+	for _, chunk := range chunkStrings(sortedKeys(byGeckoID), coinGeckoMaxIDs) {
+		if g.endpoint == CoinGeckoSimple {
+			calls = append(calls, call{geckoIDs: chunk, currencies: g.currencies})
+			continue
+		}
+		for _, currency := range g.currencies {
+			calls = append(calls, call{geckoIDs: chunk, currencies: []string{currency}})
+		}
+	}
+
+	var firstErr error
+	failed := 0
+	for _, c := range calls {
+		var err error
+		if firstErr != nil && isFatalAPIError(firstErr) {
+			err = firstErr // remaining calls would fail with the same error
+		} else if g.endpoint == CoinGeckoSimple {
+			err = g.fetchSimple(ctx, c.geckoIDs, byGeckoID, quotes.Coins)
+		} else {
+			err = g.fetchMarkets(ctx, c.geckoIDs, c.currencies[0], byGeckoID, quotes.Coins)
+		}
+		if err == nil {
+			continue
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+		cmcIDs := make([]string, 0, len(c.geckoIDs))
+		for _, id := range c.geckoIDs {
+			cmcIDs = append(cmcIDs, byGeckoID[id])
+		}
+		quotes.ChunkErrors = append(quotes.ChunkErrors, ChunkError{IDs: cmcIDs, Convert: strings.Join(c.currencies, ","), Err: err})
+	}
+	if len(calls) > 0 && failed == len(calls) {
+		quotes.Coins = nil
+		return quotes, firstErr
+	}
+
+	g.logger.Info("Fetched quotes", "calls", len(calls), "failed_calls", failed, "coins_count", len(quotes.Coins),
+		"unmapped_count", len(unmapped))
+	return quotes, nil
+}
+
+// fetchMarkets calls /coins/markets for geckoIDs in one currency and adds the coins and quotes to coins
+func (g *CoinGeckoProvider) fetchMarkets(ctx context.Context, geckoIDs []string, currency string, byGeckoID map[string]string, coins map[string]CoinInfo) error {
+	q := url.Values{}
+	q.Add("vs_currency", currency)
+	q.Add("ids", strings.Join(geckoIDs, ","))
+	q.Add("per_page", strconv.Itoa(coinGeckoMaxIDs))
+	q.Add("page", "1")
+	q.Add("price_change_percentage", "1h,24h,7d,30d")
+	q.Add("precision", "full")
+	data, err := g.rest.get(ctx, "/coins/markets", q)
+	if err != nil {
+		return err
+	}
+
+	var markets []coinGeckoMarket
+	if err := json.Unmarshal(data, &markets); err != nil {
+		g.logger.Error("failed to unmarshal markets response", "error", err)
+		return decodeError(ProviderCoinGecko, err)
+	}
+	for _, m := range markets {
+		id, ok := byGeckoID[m.ID]
+		if !ok {
+			continue
+		}
+		cmcID, _ := strconv.Atoi(id)
+		coin := coins[id]
+		coin.CmcID = cmcID
+		coin.Name = m.Name
+		coin.Symbol = strings.ToUpper(m.Symbol)
+		coin.CirculatingSupply = m.CirculatingSupply
+		coin.TotalSupply = m.TotalSupply
+		coin.MaxSupply = m.MaxSupply
+		coin.LastUpdated = m.LastUpdated
+		if coin.Quote == nil {
+			coin.Quote = make(map[string]CoinQuote)
+		}
+		coin.Quote[strings.ToUpper(currency)] = CoinQuote{
+			Price:                 m.CurrentPrice,
+			MarketCap:             m.MarketCap,
+			FullyDilutedMarketCap: m.FullyDilutedValuation,
+			Volume24H:             m.TotalVolume,
+			PercentChange1H:       m.PercentChange1H,
+			PercentChange24h:      m.PercentChange24H,
+			PercentChange7d:       m.PercentChange7D,
+			PercentChange30d:      m.PercentChange30D,
+			LastUpdated:           m.LastUpdated,
+		}
+		coins[id] = coin
+	}
+	return nil
+}
+
+// fetchSimple calls /simple/price for geckoIDs in every currency and adds the quotes to coins
+func (g *CoinGeckoProvider) fetchSimple(ctx context.Context, geckoIDs []string, byGeckoID map[string]string, coins map[string]CoinInfo) error {
+	q := url.Values{}
+	q.Add("ids", strings.Join(geckoIDs, ","))
+	q.Add("vs_currencies", strings.Join(g.currencies, ","))
+	q.Add("include_market_cap", "true")
+	q.Add("include_24hr_vol", "true")
+	q.Add("include_24hr_change", "true")
+	q.Add("include_last_updated_at", "true")
+	q.Add("precision", "full")
+	data, err := g.rest.get(ctx, "/simple/price", q)
+	if err != nil {
+		return err
+	}
+
+	var prices coinGeckoPrices
+	if err := json.Unmarshal(data, &prices); err != nil {
+		g.logger.Error("failed to unmarshal simple price response", "error", err)
+		return decodeError(ProviderCoinGecko, err)
+	}
+	for geckoID, fields := range prices {
+		id, ok := byGeckoID[geckoID]
+		if !ok {
+			continue
+		}
+		var lastUpdated CMCTime
+		if secs, err := strconv.ParseInt(fields["last_updated_at"].String(), 10, 64); err == nil {
+			lastUpdated = CMCTime{Time: time.Unix(secs, 0).UTC()}
+		}
+		cmcID, _ := strconv.Atoi(id)
+		coin := CoinInfo{CmcID: cmcID, LastUpdated: lastUpdated, Quote: make(map[string]CoinQuote)}
+		for _, currency := range g.currencies {
+			price, ok := fields[currency]
+			if !ok || price.IsNull() {
+				continue
+			}
+			coin.Quote[strings.ToUpper(currency)] = CoinQuote{
+				Price:            price,
+				MarketCap:        fields[currency+"_market_cap"],
+				Volume24H:        fields[currency+"_24h_vol"],
+				PercentChange24h: fields[currency+"_24h_change"],
+				LastUpdated:      lastUpdated,
+			}
+		}
+		coins[id] = coin
+	}
+	return nil
+}
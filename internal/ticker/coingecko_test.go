@@ -0,0 +1,119 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestCoinGeckoMarkets tests mapping of /coins/markets into the canonical model and unmapped coins
+func TestCoinGeckoMarkets(t *testing.T) {
+	var gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coins/markets" {
+			t.Errorf("Expected /coins/markets, got %s", r.URL.Path)
+		}
+		gotKey = r.Header.Get("x-cg-demo-api-key")
+		price := "67187.3358"
+		if r.URL.Query().Get("vs_currency") == "eur" {
+			price = "61000.12"
+		}
+		w.Write([]byte(`[{"id":"bitcoin","symbol":"btc","name":"Bitcoin","current_price":` + price + `,
+			"market_cap":1317802988326.25,"market_cap_rank":1,"total_volume":31260929299,"circulating_supply":19675987,
+			"total_supply":21000000,"max_supply":21000000,"price_change_percentage_24h_in_currency":3.64,
+			"last_updated":"2024-03-25T08:05:09.426Z"}]`))
+	}))
+	defer server.Close()
+
+	provider := NewCoinGeckoProvider(config.CoinGeckoSettings{
+		APIKey: "cg-key", APIKeyHeader: "x-cg-demo-api-key", BaseURL: server.URL, Endpoint: CoinGeckoMarkets,
+		VsCurrencies: []string{"USD", "eur"}, IDs: map[int]string{1: "bitcoin"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "1027"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if gotKey != "cg-key" {
+		t.Errorf("Expected API key header, got %q", gotKey)
+	}
+	btc := quotes.Coins["1"]
+	if btc.CmcID != 1 || btc.Symbol != "BTC" || btc.CirculatingSupply != "19675987" {
+		t.Errorf("Unexpected coin fields: %+v", btc)
+	}
+	if btc.CmcRank != nil {
+		t.Errorf("Expected market_cap_rank not to be stored as cmc_rank, got %d", *btc.CmcRank)
+	}
+	if quotes.QuotesOnly || !quotes.MarketFields || quotes.coinInfoMode() != coinInfoMarket {
+		t.Errorf("Expected a market fields result, got quotes only %v market fields %v", quotes.QuotesOnly, quotes.MarketFields)
+	}
+	if btc.Quote["USD"].Price != "67187.3358" || btc.Quote["EUR"].Price != "61000.12" || btc.Quote["USD"].PercentChange24h != "3.64" {
+		t.Errorf("Expected USD and EUR quotes, got %+v", btc.Quote)
+	}
+	if btc.Quote["USD"].LastUpdated.IsZero() {
+		t.Error("Expected last_updated to be set")
+	}
+	if len(quotes.ChunkErrors) != 1 || quotes.ChunkErrors[0].IDs[0] != "1027" || !errors.Is(quotes.ChunkErrors[0], ErrUnmappedCoin) {
+		t.Errorf("Expected 1027 to be unmapped, got %v", quotes.ChunkErrors)
+	}
+}
+
+// TestCoinGeckoSimple tests mapping of /simple/price into quotes only coins
+func TestCoinGeckoSimple(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/price" || r.URL.Query().Get("vs_currencies") != "usd,eur" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Write([]byte(`{"ethereum":{"usd":3500.5,"usd_market_cap":420000000000,"usd_24h_vol":15000000000,
+			"usd_24h_change":-1.25,"eur":3200.1,"last_updated_at":1711356300}}`))
+	}))
+	defer server.Close()
+
+	provider := NewCoinGeckoProvider(config.CoinGeckoSettings{
+		BaseURL: server.URL, Endpoint: CoinGeckoSimple, VsCurrencies: []string{"usd", "eur"}, IDs: map[int]string{1027: "ethereum"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1027"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !quotes.QuotesOnly {
+		t.Error("Expected quotes only result from the simple endpoint")
+	}
+	eth := quotes.Coins["1027"]
+	if eth.Quote["USD"].Price != "3500.5" || eth.Quote["USD"].PercentChange24h != "-1.25" || eth.Quote["EUR"].Price != "3200.1" {
+		t.Errorf("Unexpected quotes: %+v", eth.Quote)
+	}
+	if got := eth.Quote["USD"].LastUpdated.Unix(); got != 1711356300 {
+		t.Errorf("Expected last_updated 1711356300, got %d", got)
+	}
+}
+
+// TestCoinGeckoErrors tests that HTTP errors map to the shared sentinel errors
+func TestCoinGeckoErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":{"error_code":429,"error_message":"You've exceeded the Rate Limit."}}`))
+	}))
+	defer server.Close()
+
+	provider := NewCoinGeckoProvider(config.CoinGeckoSettings{
+		BaseURL: server.URL, VsCurrencies: []string{"usd", "eur"}, IDs: map[int]string{1: "bitcoin"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1"})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Provider != ProviderCoinGecko || apiErr.Code != 429 {
+		t.Errorf("Expected coingecko *APIError with code 429, got %v", err)
+	}
+	if len(quotes.ChunkErrors) != 2 {
+		t.Errorf("Expected both currency calls to fail, got %v", quotes.ChunkErrors)
+	}
+}
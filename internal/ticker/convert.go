@@ -0,0 +1,76 @@
+package ticker
+
+import "strings"
+
+// CMC quotes endpoints accept either "convert" (symbols: USD,EUR) or "convert_id" (CMC ID's: 2781,2790) but not both.
+// Each plan is limited to a number of convert options per call, so targets are split into groups of at most
+// MaxConvertPerCall and one request is made per group. Responses are merged back into one CMCResponse.
+
+// convertParam is one group of convert targets sent in a single request.
+type convertParam struct {
+	key    string   // "convert" or "convert_id"
+	values []string // currency symbols or CMC convert ID's
+}
+
+// String returns the query parameter value (comma separated targets)
+func (c convertParam) String() string {
+	return strings.Join(c.values, ",")
+}
+
+// buildConvertGroups splits convert symbols and convert ID's into groups of at most maxPerCall targets.
+// Defaults to a single USD group if no targets are configured.
+func buildConvertGroups(symbols, ids []string, maxPerCall int) []convertParam {
+	if maxPerCall <= 0 {
+		maxPerCall = 1
+	}
+	if len(symbols) == 0 && len(ids) == 0 {
+		symbols = []string{"USD"}
+	}
+
+	var groups []convertParam
+	for _, g := range chunkStrings(symbols, maxPerCall) {
+		groups = append(groups, convertParam{key: "convert", values: g})
+	}
+	for _, g := range chunkStrings(ids, maxPerCall) {
+		groups = append(groups, convertParam{key: "convert_id", values: g})
+	}
+	return groups
+}
+
+// chunkStrings splits a slice into chunks of at most size elements
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+	for start := 0; start < len(values); start += size {
+		end := min(start+size, len(values))
+		chunks = append(chunks, values[start:end])
+	}
+	return chunks
+}
+
+// mergeResponse merges the coins and quotes of src into dst. Quote maps are merged per coin so each coin ends up
+// with one quote per convert target. Credit counts are summed across calls.
+func mergeResponse(dst, src *CMCResponse) {
+	if dst.Data == nil {
+		dst.Data = make(map[string]CoinInfo)
+	}
+	dst.Status.CreditCount += src.Status.CreditCount
+	dst.Status.Elapsed += src.Status.Elapsed
+	if src.Status.Timestamp.After(dst.Status.Timestamp.Time) {
+		dst.Status.Timestamp = src.Status.Timestamp
+	}
+
+	for id, coin := range src.Data {
+		existing, ok := dst.Data[id]
+		if !ok {
+			dst.Data[id] = coin
+			continue
+		}
+		if existing.Quote == nil {
+			existing.Quote = make(map[string]CoinQuote)
+		}
+		for currency, quote := range coin.Quote {
+			existing.Quote[currency] = quote
+		}
+		dst.Data[id] = existing
+	}
+}
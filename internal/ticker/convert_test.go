@@ -0,0 +1,76 @@
+package ticker
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestBuildConvertGroups tests splitting convert targets by plan limit and by parameter type
+func TestBuildConvertGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		symbols    []string
+		ids        []string
+		maxPerCall int
+		want       []convertParam
+	}{
+		{
+			name: "defaults to USD",
+			want: []convertParam{{key: "convert", values: []string{"USD"}}},
+		},
+		{
+			name: "ids only",
+			ids:  []string{"2781"},
+			want: []convertParam{{key: "convert_id", values: []string{"2781"}}},
+		},
+		{
+			name:       "split symbols by plan limit",
+			symbols:    []string{"USD", "EUR", "CAD"},
+			maxPerCall: 2,
+			want: []convertParam{
+				{key: "convert", values: []string{"USD", "EUR"}},
+				{key: "convert", values: []string{"CAD"}},
+			},
+		},
+		{
+			name:       "symbols and ids in separate calls",
+			symbols:    []string{"USD"},
+			ids:        []string{"1"},
+			maxPerCall: 3,
+			want: []convertParam{
+				{key: "convert", values: []string{"USD"}},
+				{key: "convert_id", values: []string{"1"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildConvertGroups(tt.symbols, tt.ids, tt.maxPerCall)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildConvertGroups() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeResponse tests that quotes from separate convert calls are merged per coin
+func TestMergeResponse(t *testing.T) {
+	merged := &CMCResponse{}
+	mergeResponse(merged, &CMCResponse{
+		Status: Status{CreditCount: 1},
+		Data:   map[string]CoinInfo{"1": {CmcID: 1, Quote: map[string]CoinQuote{"USD": {Price: "50000"}}}},
+	})
+	mergeResponse(merged, &CMCResponse{
+		Status: Status{CreditCount: 1},
+		Data:   map[string]CoinInfo{"1": {CmcID: 1, Quote: map[string]CoinQuote{"EUR": {Price: "46000"}}}},
+	})
+
+	if merged.Status.CreditCount != 2 {
+		t.Errorf("Expected credit count 2, got %d", merged.Status.CreditCount)
+	}
+	quotes := merged.Data["1"].Quote
+	if quotes["USD"].Price != "50000" || quotes["EUR"].Price != "46000" {
+		t.Errorf("Expected USD and EUR quotes to be merged, got %+v", quotes)
+	}
+}
@@ -0,0 +1,258 @@
+package ticker
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// CMC returns prices of sub-cent tokens with more significant digits than float64 keeps and supplies of meme coins
+// that overflow NUMERIC(20,8). Decimal keeps every number exactly as written in the JSON response and is passed to
+// Postgres as text so NUMERIC columns store the exact value. Float64 is only used for the approximate math in
+// validation. Columns are sized for real-world values (migrations/ticker/010). Before writing, fitNumerics checks
+// every value against its column: a value that still does not fit is stored as NULL and reported as a
+// NumericOverflow, a price that does not fit rejects the quote. The transaction never fails on a numeric overflow.
+
+// ErrNumericOverflow is recorded when a price does not fit its NUMERIC column
+var ErrNumericOverflow = errors.New("value does not fit numeric column")
+
+// Decimal holds an exact decimal number from CMC JSON. The zero value ("") is null.
+type Decimal string
+
+// numericSpec is the precision and scale of a NUMERIC column
+type numericSpec struct {
+	precision int
+	scale     int
+}
+
+// NUMERIC column types (see migrations/ticker/010_widen_numeric_columns)
+var (
+	priceNumeric   = numericSpec{precision: 40, scale: 18} // prices and candle OHLC
+	amountNumeric  = numericSpec{precision: 40, scale: 8}  // supplies, market caps, volumes and TVL
+	percentNumeric = numericSpec{precision: 30, scale: 8}  // percent changes and ratios
+)
+
+// NumericOverflow holds a value that did not fit its column and was not stored.
+type NumericOverflow struct {
+	CmcID    int
+	Symbol   string
+	Currency string // empty for coin_info fields
+	Field    string // JSON field name
+	Value    Decimal
+}
+
+// IsNull returns true if the value is null or missing
+func (d Decimal) IsNull() bool {
+	return d == ""
+}
+
+// String returns the decimal as written upstream
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Float64 returns the nearest float64 (0 if null). Values out of the float64 range return +Inf or -Inf.
+func (d Decimal) Float64() float64 {
+	if d.IsNull() {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(string(d), 64)
+	return f
+}
+
+// Cmp compares d and o exactly. Returns -1, 0 or +1.
+func (d Decimal) Cmp(o Decimal) int {
+	a, okA := new(big.Rat).SetString(string(d))
+	b, okB := new(big.Rat).SetString(string(o))
+	if !okA || !okB {
+		return cmp.Compare(d.Float64(), o.Float64())
+	}
+	return a.Cmp(b)
+}
+
+// Mul returns d * o rounded to scale decimals. Returns null if either value is null or not a number.
+func (d Decimal) Mul(o Decimal, scale int) Decimal {
+	a, okA := new(big.Rat).SetString(string(d))
+	b, okB := new(big.Rat).SetString(string(o))
+	if !okA || !okB {
+		return ""
+	}
+	return Decimal(a.Mul(a, b).FloatString(scale))
+}
+
+// percentChange returns the percent change from open to last rounded to the percent column scale. Returns null if
+// either value is null or open is zero. Used by exchange providers that report the 24h open instead of the change.
+func percentChange(open, last Decimal) Decimal {
+	o, okO := new(big.Rat).SetString(string(open))
+	l, okL := new(big.Rat).SetString(string(last))
+	if !okO || !okL || o.Sign() == 0 {
+		return ""
+	}
+	change := new(big.Rat).Sub(l, o)
+	change.Quo(change, o).Mul(change, big.NewRat(100, 1))
+	return Decimal(change.FloatString(percentNumeric.scale))
+}
+
+// fits reports whether d can be stored in a column of spec after Postgres rounds it to the column scale
+func (d Decimal) fits(spec numericSpec) bool {
+	if d.IsNull() {
+		return true
+	}
+	r, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return false
+	}
+	// Largest absolute value that rounds below 10^(precision-scale): 10^(precision-scale) - 0.5 * 10^-scale
+	limit := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(spec.precision-spec.scale)), nil))
+	half := new(big.Rat).SetFrac(big.NewInt(5), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(spec.scale+1)), nil))
+	limit.Sub(limit, half)
+	return r.Abs(r).Cmp(limit) < 0
+}
+
+// UnmarshalJSON decodes a JSON number (or a number in a string) without rounding. null and "" decode to null.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if s := string(data); s == "null" || s == `""` {
+		*d = ""
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid decimal %s: %w", data, err)
+	}
+	*d = Decimal(n)
+	return nil
+}
+
+// MarshalJSON encodes the decimal as a JSON number or null
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d.IsNull() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(json.Number(d))
+}
+
+// Value implements driver.Valuer. The decimal is sent as text so NUMERIC columns store it exactly, null as NULL.
+func (d Decimal) Value() (driver.Value, error) {
+	if d.IsNull() {
+		return nil, nil
+	}
+	return string(d), nil
+}
+
+// Scan implements sql.Scanner for nullable NUMERIC columns
+func (d *Decimal) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = ""
+	case []byte:
+		*d = Decimal(v)
+	case string:
+		*d = Decimal(v)
+	case float64:
+		*d = Decimal(strconv.FormatFloat(v, 'f', -1, 64))
+	case int64:
+		*d = Decimal(strconv.FormatInt(v, 10))
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", src)
+	}
+	return nil
+}
+
+// fitOrNil returns d, or nil if it does not fit a column of spec
+func fitOrNil(d Decimal, spec numericSpec) any {
+	if !d.fits(spec) {
+		return nil
+	}
+	return d
+}
+
+// overflowRecorder collects the values of one coin that do not fit their columns
+type overflowRecorder struct {
+	cmcID     int
+	symbol    string
+	currency  string
+	overflows []NumericOverflow
+}
+
+// fit returns v, or null if it does not fit spec (recorded as an overflow)
+func (r *overflowRecorder) fit(field string, v Decimal, spec numericSpec) Decimal {
+	if v.fits(spec) {
+		return v
+	}
+	r.overflows = append(r.overflows, NumericOverflow{
+		CmcID: r.cmcID, Symbol: r.symbol, Currency: r.currency, Field: field, Value: v,
+	})
+	return ""
+}
+
+// fitPtr returns v, or nil if it does not fit spec (recorded as an overflow)
+func (r *overflowRecorder) fitPtr(field string, v *Decimal, spec numericSpec) *Decimal {
+	if v == nil || v.fits(spec) {
+		return v
+	}
+	r.fit(field, *v, spec)
+	return nil
+}
+
+// fitQuote returns the quote with every nullable value that does not fit its column set to null.
+// Returns false if the price does not fit (the quote cannot be stored).
+func (r *overflowRecorder) fitQuote(q CoinQuote) (CoinQuote, bool) {
+	if !q.Price.fits(priceNumeric) {
+		r.fit("price", q.Price, priceNumeric)
+		return q, false
+	}
+	q.MarketCap = r.fit("market_cap", q.MarketCap, amountNumeric)
+	q.FullyDilutedMarketCap = r.fit("fully_diluted_market_cap", q.FullyDilutedMarketCap, amountNumeric)
+	q.Volume24H = r.fit("volume_24h", q.Volume24H, amountNumeric)
+	q.Volume24HReported = r.fitPtr("volume_24h_reported", q.Volume24HReported, amountNumeric)
+	q.VolumeChange24H = r.fit("volume_change_24h", q.VolumeChange24H, percentNumeric)
+	q.PercentChange1H = r.fit("percent_change_1h", q.PercentChange1H, percentNumeric)
+	q.PercentChange24h = r.fit("percent_change_24h", q.PercentChange24h, percentNumeric)
+	q.PercentChange7d = r.fit("percent_change_7d", q.PercentChange7d, percentNumeric)
+	q.PercentChange30d = r.fit("percent_change_30d", q.PercentChange30d, percentNumeric)
+	q.PercentChange60d = r.fit("percent_change_60d", q.PercentChange60d, percentNumeric)
+	q.PercentChange90d = r.fit("percent_change_90d", q.PercentChange90d, percentNumeric)
+	q.Tvl = r.fitPtr("tvl", q.Tvl, amountNumeric)
+	return q, true
+}
+
+// fitNumerics checks every numeric value against its column. Values that do not fit are set to null and returned
+// as overflows. Quotes with a price that does not fit are dropped and coins left without quotes are returned as
+// invalid outcomes.
+func fitNumerics(data map[string]CoinInfo) (map[string]CoinInfo, []NumericOverflow, []CoinOutcome) {
+	fitted := make(map[string]CoinInfo, len(data))
+	var overflows []NumericOverflow
+	var outcomes []CoinOutcome
+
+	for _, k := range sortedKeys(data) {
+		coin := data[k]
+		r := &overflowRecorder{cmcID: coin.CmcID, symbol: coin.Symbol}
+		coin.CirculatingSupply = r.fit("circulating_supply", coin.CirculatingSupply, amountNumeric)
+		coin.TotalSupply = r.fit("total_supply", coin.TotalSupply, amountNumeric)
+		coin.MaxSupply = r.fitPtr("max_supply", coin.MaxSupply, amountNumeric)
+		coin.SelfReportedCirculatingSupply = r.fitPtr("self_reported_circulating_supply", coin.SelfReportedCirculatingSupply, amountNumeric)
+		coin.SelfReportedMarketCap = r.fitPtr("self_reported_market_cap", coin.SelfReportedMarketCap, amountNumeric)
+		coin.TvlRatio = r.fitPtr("tvl_ratio", coin.TvlRatio, percentNumeric)
+
+		quotes := make(map[string]CoinQuote, len(coin.Quote))
+		for _, currency := range sortedKeys(coin.Quote) {
+			r.currency = currency
+			quote, ok := r.fitQuote(coin.Quote[currency])
+			if ok {
+				quotes[currency] = quote
+			}
+		}
+		overflows = append(overflows, r.overflows...)
+		if len(quotes) == 0 {
+			outcomes = append(outcomes, CoinOutcome{CmcID: coin.CmcID, Symbol: coin.Symbol, Status: OutcomeInvalid, Err: ErrNumericOverflow})
+			continue
+		}
+		coin.Quote = quotes
+		fitted[k] = coin
+	}
+	return fitted, overflows, outcomes
+}
@@ -0,0 +1,111 @@
+package ticker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Coinmarketcap API error codes: https://coinmarketcap.com/api/documentation/v1/#section/Errors-and-Rate-Limits
+// Callers match on the sentinel errors below with errors.Is(). Use errors.As() with *APIError to get the raw code and message.
+// Every QuoteProvider returns the same sentinel errors: CMC error codes are mapped by newAPIError, providers that
+// report errors with HTTP status codes use newHTTPError. Providers with their own error format (Binance codes, the
+// Kraken error array) map it to the same sentinel errors.
+
+// Sentinel errors returned (wrapped in *APIError) when an upstream API reports an error.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrPlanLimit    = errors.New("plan limit")
+	ErrInvalidID    = errors.New("invalid id")
+	ErrAPI          = errors.New("api error")
+)
+
+// ErrDecode is returned when the response body cannot be unmarshalled into the response struct.
+var ErrDecode = errors.New("failed to decode response")
+
+// APIError holds the error code and message returned by an upstream API.
+type APIError struct {
+	Provider string // provider name (ex. cmc)
+	Code     int    // CMC error code, provider error code or HTTP status code (0 if the provider has none)
+	Message  string
+	Err      error // one of the sentinel errors above
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %v (code %d): %s", e.Provider, e.Err, e.Code, e.Message)
+}
+
+// Unwrap allows errors.Is() to match the sentinel error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError maps a CMC error code and message to an *APIError wrapping the matching sentinel error.
+func newAPIError(code int, message string) *APIError {
+	return &APIError{
+		Provider: ProviderCMC,
+		Code:     code,
+		Message:  message,
+		Err:      classifyAPIError(code, message),
+	}
+}
+
+// newHTTPError maps the HTTP status code of a failed provider call to an *APIError wrapping the matching sentinel error.
+func newHTTPError(provider string, status int, message string) *APIError {
+	return &APIError{
+		Provider: provider,
+		Code:     status,
+		Message:  message,
+		Err:      classifyHTTPStatus(status),
+	}
+}
+
+// classifyAPIError returns the sentinel error for a CMC error code.
+// 1001-1002 & 1005, 1007: API key invalid, missing or disabled.
+// 1003-1004 & 1006: plan requires payment, payment expired or endpoint not available on plan.
+// 1008-1011: minute, daily, monthly or IP rate limit reached.
+// 400: bad request. CMC reports unknown ID's as 400 with an "Invalid value for "id"" message.
+func classifyAPIError(code int, message string) error {
+	switch code {
+	case 1001, 1002, 1005, 1007:
+		return ErrUnauthorized
+	case 1003, 1004, 1006:
+		return ErrPlanLimit
+	case 1008, 1009, 1010, 1011:
+		return ErrRateLimited
+	case 400:
+		msg := strings.ToLower(message)
+		if strings.Contains(msg, "plan") {
+			return ErrPlanLimit
+		}
+		if strings.Contains(msg, `"id"`) || strings.Contains(msg, "invalid id") {
+			return ErrInvalidID
+		}
+	case 401:
+		return ErrUnauthorized
+	case 402, 403:
+		return ErrPlanLimit
+	case 418, 429:
+		return ErrRateLimited
+	}
+	return ErrAPI
+}
+
+// classifyHTTPStatus returns the sentinel error for an HTTP status code.
+// 404 is returned by providers for unknown coins or trading pairs. Binance returns 418 once an IP is banned for
+// ignoring 429 responses.
+func classifyHTTPStatus(status int) error {
+	switch status {
+	case 401:
+		return ErrUnauthorized
+	case 402, 403:
+		return ErrPlanLimit
+	case 404:
+		return ErrInvalidID
+	case 418, 429:
+		return ErrRateLimited
+	}
+	return ErrAPI
+}
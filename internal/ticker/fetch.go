@@ -0,0 +1,117 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// CMC limits the number of ID's per call and long ID lists break URL length limits.
+// Tracked ID's are split into chunks of chunkSize and every chunk is fetched once per convert group.
+// Calls run concurrently (at most workers at a time) under the parent context and are merged into one CMCResponse.
+
+// fetchJob is a single call to the CMC quotes endpoint
+type fetchJob struct {
+	ids     []string
+	convert convertParam
+}
+
+// fetchResult holds the decoded response or error for a fetchJob
+type fetchResult struct {
+	resp *CMCResponse
+	err  error
+}
+
+// fetchQuotes fetches quotes for ids in chunks with bounded concurrency and merges the chunks into one CMCResponse.
+// A failing chunk is reported in the returned ChunkErrors and does not stop the other chunks, unless the error
+// applies to every call (unauthorized, rate limited, plan limit) in which case the remaining calls are cancelled.
+// Returns an error only if every chunk failed.
+func (c *CMCProvider) fetchQuotes(ctx context.Context, ids []string) (*CMCResponse, []ChunkError, error) {
+	jobs := buildFetchJobs(ids, c.convert, c.chunkSize)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := max(c.workers, 1)
+	sem := make(chan struct{}, workers) // limits concurrent calls
+	results := make([]fetchResult, len(jobs))
+	var wg sync.WaitGroup
+
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = fetchResult{err: ctx.Err()}
+				return
+			}
+
+			resp, err := c.fetchChunk(ctx, job)
+			if isFatalAPIError(err) {
+				cancel() // remaining chunks would fail with the same error
+			}
+			results[i] = fetchResult{resp: resp, err: err}
+		}()
+	}
+	wg.Wait()
+
+	// Merge in job order so the result does not depend on goroutine scheduling
+	merged := &CMCResponse{Data: make(map[string]CoinInfo)}
+	var chunkErrs []ChunkError
+	var firstErr error
+	for i, r := range results {
+		if r.err != nil {
+			chunkErrs = append(chunkErrs, ChunkError{IDs: jobs[i].ids, Convert: jobs[i].convert.String(), Err: r.err})
+			// Prefer the error that caused the cancellation over context.Canceled from other chunks
+			if firstErr == nil || (errors.Is(firstErr, context.Canceled) && !errors.Is(r.err, context.Canceled)) {
+				firstErr = r.err
+			}
+			continue
+		}
+		mergeResponse(merged, r.resp)
+	}
+	if len(chunkErrs) == len(jobs) && firstErr != nil {
+		return nil, chunkErrs, firstErr
+	}
+
+	c.logger.Info("Fetched quotes",
+		"calls", len(jobs),
+		"failed_calls", len(chunkErrs),
+		"coins_count", len(merged.Data),
+		"credit_count", merged.Status.CreditCount)
+	return merged, chunkErrs, nil
+}
+
+// isFatalAPIError returns true for API errors that apply to every call made with the same API key
+func isFatalAPIError(err error) bool {
+	return errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrPlanLimit)
+}
+
+// fetchChunk calls the CMC API for one job and decodes the response
+func (c *CMCProvider) fetchChunk(ctx context.Context, job fetchJob) (*CMCResponse, error) {
+	// Call and return data from CMC API as []byte
+	data, err := c.CallAPI(ctx, job.ids, job.convert)
+	if err != nil {
+		c.logger.Error("failed to fetch data", "error", err, "ids_count", len(job.ids), job.convert.key, job.convert.String())
+		return nil, err
+	}
+	// Decode []byte data into CMCResponse struct
+	return c.DecodeData(data)
+}
+
+// buildFetchJobs returns one job per chunk of ids per convert group
+func buildFetchJobs(ids []string, convert []convertParam, chunkSize int) []fetchJob {
+	if chunkSize <= 0 {
+		chunkSize = len(ids)
+	}
+	var jobs []fetchJob
+	for _, chunk := range chunkStrings(ids, max(chunkSize, 1)) {
+		for _, c := range convert {
+			jobs = append(jobs, fetchJob{ids: chunk, convert: c})
+		}
+	}
+	return jobs
+}
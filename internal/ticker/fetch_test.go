@@ -0,0 +1,96 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestFetchQuotesChunks tests that ID's are fetched in chunks with bounded concurrency and merged into one response
+func TestFetchQuotesChunks(t *testing.T) {
+	var calls, inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		ids := strings.Split(r.URL.Query().Get("id"), ",")
+		if len(ids) > 2 {
+			t.Errorf("Expected at most 2 ID's per call, got %d", len(ids))
+		}
+		var data []string
+		for _, id := range ids {
+			data = append(data, fmt.Sprintf(`"%s":{"id":%s,"quote":{"USD":{"price":1}}}`, id, id))
+		}
+		fmt.Fprintf(w, `{"status":{"error_code":0,"credit_count":1},"data":{%s}}`, strings.Join(data, ","))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{
+		CMC: config.CMCSettings{
+			QuotesURL:      server.URL,
+			IDChunkSize:    2,
+			MaxConcurrency: 2,
+		},
+	}
+	provider := NewCMCProvider(cfg.CMC, server.Client(), testLogger())
+
+	resp, chunkErrs, err := provider.fetchQuotes(context.Background(), []string{"1", "2", "3", "4", "5"})
+	if err != nil || len(chunkErrs) != 0 {
+		t.Fatalf("Expected no error, got %v %v", err, chunkErrs)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls.Load())
+	}
+	if maxInFlight.Load() > 2 {
+		t.Errorf("Expected at most 2 concurrent calls, got %d", maxInFlight.Load())
+	}
+	if len(resp.Data) != 5 {
+		t.Errorf("Expected 5 coins, got %d", len(resp.Data))
+	}
+	if resp.Status.CreditCount != 3 {
+		t.Errorf("Expected credit count 3, got %d", resp.Status.CreditCount)
+	}
+}
+
+// TestFetchQuotesChunkError tests that a failing chunk is reported without dropping the other chunks
+func TestFetchQuotesChunkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") == "3" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"status":{"error_code":500,"error_message":"internal error"}}`))
+			return
+		}
+		w.Write([]byte(`{"status":{"error_code":0,"credit_count":1,"elapsed":10},"data":{"1":{"id":1,"quote":{"USD":{"price":1}}}}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL, IDChunkSize: 2}}
+	provider := NewCMCProvider(cfg.CMC, server.Client(), testLogger())
+
+	resp, chunkErrs, err := provider.fetchQuotes(context.Background(), []string{"1", "2", "3"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(chunkErrs) != 1 || chunkErrs[0].IDs[0] != "3" || !errors.Is(chunkErrs[0], ErrAPI) {
+		t.Errorf("Expected one ErrAPI chunk error for id 3, got %v", chunkErrs)
+	}
+	if len(resp.Data) != 1 {
+		t.Errorf("Expected 1 coin from the healthy chunk, got %d", len(resp.Data))
+	}
+}
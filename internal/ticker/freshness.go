@@ -0,0 +1,133 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CMC often returns the same last_updated for a coin across several ticks. Quotes whose upstream last_updated
+// matches the stored coin_quote row are not written again. Coins whose upstream last_updated is older than the
+// staleness window are flagged (CoinOutcome.Stale) to detect when CMC stopped updating a coin.
+
+const selectStoredQuotesSQL = `
+SELECT ci.cmc_id, cq.currency, cq.price, cq.last_updated
+FROM coin_quote cq
+JOIN coin_info ci ON ci.id = cq.coin_id
+WHERE ci.cmc_id = ANY($1)`
+
+// selectRejectedMovesSQL returns the prices of quotes quarantined for a price move since the last stored quote,
+// newest first
+const selectRejectedMovesSQL = `
+SELECT q.cmc_id, q.currency, q.price
+FROM coin_quote_quarantine q
+JOIN coin_info ci ON ci.cmc_id = q.cmc_id
+JOIN coin_quote cq ON cq.coin_id = ci.id AND cq.currency = q.currency
+WHERE q.cmc_id = ANY($1) AND q.reason LIKE $2 AND q.price IS NOT NULL
+    AND (cq.last_updated IS NULL OR q.last_updated > cq.last_updated)
+ORDER BY q.last_updated DESC`
+
+// quoteKey identifies a stored quote row (one per coin per currency)
+type quoteKey struct {
+	cmcID    int
+	currency string
+}
+
+// storedQuote holds the fields of a stored coin_quote row used to compare against upstream data
+type storedQuote struct {
+	price       float64
+	lastUpdated time.Time
+	// prices of the quotes quarantined for a price move since the stored quote, newest first
+	rejectedMoves []float64
+}
+
+// loadStoredQuotes returns the stored coin_quote rows for the coins in data with their quarantined price moves
+func loadStoredQuotes(ctx context.Context, sqlDB *sql.DB, data map[string]CoinInfo) (map[quoteKey]storedQuote, error) {
+	ids := make([]int64, 0, len(data))
+	for _, coin := range data {
+		ids = append(ids, int64(coin.CmcID))
+	}
+
+	rows, err := sqlDB.QueryContext(ctx, selectStoredQuotesSQL, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored quotes: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[quoteKey]storedQuote)
+	for rows.Next() {
+		var key quoteKey
+		var price sql.NullFloat64
+		var lastUpdated CMCTime
+		if err := rows.Scan(&key.cmcID, &key.currency, &price, &lastUpdated); err != nil {
+			return nil, fmt.Errorf("failed to scan stored quote: %w", err)
+		}
+		stored[key] = storedQuote{price: price.Float64, lastUpdated: lastUpdated.Time}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stored quotes: %w", err)
+	}
+
+	moves, err := sqlDB.QueryContext(ctx, selectRejectedMovesSQL, pq.Array(ids), ErrPriceMove.Error()+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejected price moves: %w", err)
+	}
+	defer moves.Close()
+	for moves.Next() {
+		var key quoteKey
+		var price float64
+		if err := moves.Scan(&key.cmcID, &key.currency, &price); err != nil {
+			return nil, fmt.Errorf("failed to scan rejected price move: %w", err)
+		}
+		if prev, ok := stored[key]; ok {
+			prev.rejectedMoves = append(prev.rejectedMoves, price)
+			stored[key] = prev
+		}
+	}
+	if err := moves.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rejected price moves: %w", err)
+	}
+	return stored, nil
+}
+
+// filterUnchanged drops quotes whose upstream last_updated matches the stored row. Coins left without quotes are
+// returned as unchanged outcomes and removed from the returned data.
+func filterUnchanged(data map[string]CoinInfo, stored map[quoteKey]storedQuote) (map[string]CoinInfo, []CoinOutcome) {
+	changed := make(map[string]CoinInfo, len(data))
+	var unchanged []CoinOutcome
+	for _, k := range sortedKeys(data) {
+		coin := data[k]
+		quotes := make(map[string]CoinQuote, len(coin.Quote))
+		for currency, quote := range coin.Quote {
+			prev, ok := stored[quoteKey{cmcID: coin.CmcID, currency: currency}]
+			if ok && !quote.LastUpdated.IsZero() && !prev.lastUpdated.IsZero() && quote.LastUpdated.Equal(prev.lastUpdated) {
+				continue
+			}
+			quotes[currency] = quote
+		}
+		if len(quotes) == 0 {
+			unchanged = append(unchanged, CoinOutcome{CmcID: coin.CmcID, Symbol: coin.Symbol, Status: OutcomeUnchanged})
+			continue
+		}
+		coin.Quote = quotes
+		changed[k] = coin
+	}
+	return changed, unchanged
+}
+
+// isStale returns true if every quote of the coin has an upstream last_updated older than window.
+// A zero window disables the check.
+func isStale(coin CoinInfo, now time.Time, window time.Duration) bool {
+	if window <= 0 || len(coin.Quote) == 0 {
+		return false
+	}
+	for _, quote := range coin.Quote {
+		if quote.LastUpdated.IsZero() || now.Sub(quote.LastUpdated.Time) <= window {
+			return false
+		}
+	}
+	return true
+}
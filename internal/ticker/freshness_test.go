@@ -0,0 +1,69 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFilterUnchanged tests that quotes with the stored upstream last_updated are not written again
+func TestFilterUnchanged(t *testing.T) {
+	stored := map[quoteKey]storedQuote{
+		{cmcID: 1, currency: "USD"}:    {lastUpdated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{cmcID: 1027, currency: "USD"}: {lastUpdated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	data := map[string]CoinInfo{
+		"1": {CmcID: 1, Quote: map[string]CoinQuote{
+			"USD": {Price: "50000", LastUpdated: cmcTime("2024-01-01T00:00:00.000Z")},
+		}},
+		"1027": {CmcID: 1027, Quote: map[string]CoinQuote{
+			"USD": {Price: "3000", LastUpdated: cmcTime("2024-01-01T00:00:00.000Z")},
+			"EUR": {Price: "2700", LastUpdated: cmcTime("2024-01-01T00:00:00.000Z")}, // not stored yet
+		}},
+		"5994": {CmcID: 5994, Quote: map[string]CoinQuote{
+			"USD": {Price: "100", LastUpdated: cmcTime("2024-01-01T00:02:00.000Z")}, // new coin
+		}},
+	}
+
+	changed, unchanged := filterUnchanged(data, stored)
+
+	if len(unchanged) != 1 || unchanged[0].CmcID != 1 || unchanged[0].Status != OutcomeUnchanged {
+		t.Errorf("Expected cmc_id 1 unchanged, got %+v", unchanged)
+	}
+	if _, ok := changed["1"]; ok {
+		t.Error("Expected cmc_id 1 to be skipped")
+	}
+	if quotes := changed["1027"].Quote; len(quotes) != 1 || quotes["EUR"].Price != "2700" {
+		t.Errorf("Expected only the EUR quote for cmc_id 1027, got %+v", quotes)
+	}
+	if _, ok := changed["5994"]; !ok {
+		t.Error("Expected cmc_id 5994 to be written")
+	}
+}
+
+// TestIsStale tests flagging coins whose upstream last_updated has not advanced within the staleness window
+func TestIsStale(t *testing.T) {
+	now := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	coin := func(lastUpdated string) CoinInfo {
+		return CoinInfo{Quote: map[string]CoinQuote{"USD": {LastUpdated: cmcTime(lastUpdated)}}}
+	}
+
+	tests := []struct {
+		name   string
+		coin   CoinInfo
+		window time.Duration
+		want   bool
+	}{
+		{"fresh", coin("2024-01-01T00:59:00.000Z"), 30 * time.Minute, false},
+		{"stale", coin("2024-01-01T00:00:00.000Z"), 30 * time.Minute, true},
+		{"check disabled", coin("2024-01-01T00:00:00.000Z"), 0, false},
+		{"missing timestamp", coin(""), 30 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStale(tt.coin, now, tt.window); got != tt.want {
+				t.Errorf("isStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+	"github.com/lib/pq"
+)
+
+// Downtime leaves holes in coin_quote_history. RepairGaps scans the last TICKER_GAP_LOOKBACK of history per tracked
+// coin for gaps longer than TICKER_GAP_TOLERANCE times the expected interval (the coin's tier interval, or the
+// historical interval used to repair it if larger) and fills each gap with Backfill. Each series is seeded with its
+// last point before the lookback window, so a gap that started before the window is found too. A gap still open at
+// scan time (no point after it yet) is left alone until the next point closes it: its end would move on every scan
+// and count the same gap against the retry limit. Every gap is recorded in coin_quote_gap as filled or unfilled with
+// the reason. Unfilled gaps are retried on later scans up to maxGapAttempts times. CMC_BACKFILL_CREDIT_CAP applies to
+// the whole pass, not to each gap.
+// main runs RepairGaps at startup and every TICKER_GAP_SCAN_INTERVAL.
+
+// Gap statuses stored in coin_quote_gap.status
+const (
+	GapFilled   = "filled"
+	GapUnfilled = "unfilled"
+)
+
+// maxGapAttempts is the number of repairs tried for a gap before it is left unfilled
+const maxGapAttempts = 3
+
+// ErrGapNoData is recorded when the historical source has no points inside a gap
+var ErrGapNoData = errors.New("no historical data for gap")
+
+// historicalIntervals are the intervals accepted by the CMC historical endpoint, smallest first
+var historicalIntervals = []Resolution{
+	{Name: "5m", Duration: 5 * time.Minute},
+	{Name: "10m", Duration: 10 * time.Minute},
+	{Name: "15m", Duration: 15 * time.Minute},
+	{Name: "30m", Duration: 30 * time.Minute},
+	{Name: "45m", Duration: 45 * time.Minute},
+	{Name: "1h", Duration: time.Hour},
+	{Name: "2h", Duration: 2 * time.Hour},
+	{Name: "3h", Duration: 3 * time.Hour},
+	{Name: "4h", Duration: 4 * time.Hour},
+	{Name: "6h", Duration: 6 * time.Hour},
+	{Name: "12h", Duration: 12 * time.Hour},
+	{Name: "24h", Duration: 24 * time.Hour},
+}
+
+const (
+	// selectHistoryGapsSQL returns the gaps longer than $3 seconds between consecutive history points since $2.
+	// The last point before $2 of every series is included so gaps reaching into the window are returned.
+	// The last point of a series has no next point and is not returned (open gap).
+	selectHistoryGapsSQL = `
+WITH points AS (
+    SELECT h.coin_id, h.currency, h.last_updated
+    FROM coin_quote_history h
+    JOIN coin_info ci ON ci.id = h.coin_id
+    WHERE ci.cmc_id = ANY($1) AND h.last_updated >= $2
+    UNION ALL
+    (SELECT DISTINCT ON (h.coin_id, h.currency) h.coin_id, h.currency, h.last_updated
+    FROM coin_quote_history h
+    JOIN coin_info ci ON ci.id = h.coin_id
+    WHERE ci.cmc_id = ANY($1) AND h.last_updated < $2
+    ORDER BY h.coin_id, h.currency, h.last_updated DESC)
+)
+SELECT cmc_id, currency, gap_start, gap_end FROM (
+    SELECT ci.cmc_id, p.currency, p.last_updated AS gap_start, LEAD(p.last_updated) OVER w AS gap_end
+    FROM points p
+    JOIN coin_info ci ON ci.id = p.coin_id
+    WINDOW w AS (PARTITION BY p.coin_id, p.currency ORDER BY p.last_updated)
+) g
+WHERE gap_end - gap_start > make_interval(secs => $3)
+ORDER BY cmc_id, currency, gap_start`
+
+	// upsertGapSQL records a detected gap or returns the existing record
+	upsertGapSQL = `
+INSERT INTO coin_quote_gap (cmc_id, currency, gap_start, gap_end)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT ON CONSTRAINT unique_coin_quote_gap DO UPDATE SET
+    gap_end = GREATEST(coin_quote_gap.gap_end, EXCLUDED.gap_end),
+    updated_at = CURRENT_TIMESTAMP
+RETURNING id, status, attempts`
+
+	updateGapSQL = `
+UPDATE coin_quote_gap SET
+    status = $2,
+    points_filled = points_filled + $3,
+    attempts = attempts + 1,
+    last_error = $4,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1`
+)
+
+// gapParams holds the gap repair settings (TICKER_GAP_*)
+type gapParams struct {
+	lookback  time.Duration
+	tolerance float64                  // multiple of the expected interval, at least 1
+	intervals map[string]time.Duration // expected interval per tier
+	fallback  time.Duration            // expected interval of tiers without an interval
+}
+
+// QuoteGap holds a gap in the quote history of one coin and currency. Maps to a coin_quote_gap row.
+type QuoteGap struct {
+	CmcID    int
+	Currency string
+	Start    time.Time // last history point before the gap
+	End      time.Time // next history point
+	Interval string    // historical interval used to repair the gap
+	Points   int       // history points written by the repair
+	Err      error     // reason the gap was not filled
+}
+
+// GapReport holds the result of a gap repair run.
+type GapReport struct {
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Detected    int
+	Skipped     int // already filled or out of attempts
+	Filled      []QuoteGap
+	Unfilled    []QuoteGap
+	CreditCount int
+}
+
+// buildGapParams returns the gap repair settings with the expected interval of every configured tier
+func buildGapParams(interval config.IntervalSettings) gapParams {
+	intervals := make(map[string]time.Duration, len(interval.Tiers))
+	for _, tier := range interval.Tiers {
+		intervals[tier.Name] = tier.Interval
+	}
+	return gapParams{
+		lookback:  interval.GapLookback,
+		tolerance: max(interval.GapTolerance, 1),
+		intervals: intervals,
+		fallback:  interval.TickerInterval,
+	}
+}
+
+// historicalInterval returns the smallest historical interval covering d, or the largest one
+func historicalInterval(d time.Duration) Resolution {
+	for _, res := range historicalIntervals {
+		if res.Duration >= d {
+			return res
+		}
+	}
+	return historicalIntervals[len(historicalIntervals)-1]
+}
+
+// gapThreshold returns the repair interval of a tier and the min gap length to repair
+func (p gapParams) gapThreshold(tier string) (Resolution, time.Duration) {
+	expected, ok := p.intervals[tier]
+	if !ok || expected <= 0 {
+		expected = p.fallback
+	}
+	repair := historicalInterval(expected)
+	return repair, time.Duration(p.tolerance * float64(max(expected, repair.Duration)))
+}
+
+// RepairGaps detects gaps in the recent quote history of the tracked coins, backfills them and records the result
+// in coin_quote_gap. The backfills of one pass share the backfill credit cap. Stops repairing when the cap or budget
+// is reached, the remaining gaps are reported as unfilled and retried on the next run.
+func (t *TickerService) RepairGaps(ctx context.Context) (*GapReport, error) {
+	report := &GapReport{StartedAt: t.now()}
+	defer func() { report.FinishedAt = t.now() }()
+
+	sqlDB := t.database()
+	if sqlDB == nil {
+		return report, fmt.Errorf("repair gaps: database not connected")
+	}
+	byTier, err := t.trackedIDsByTier(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	now := t.now().UTC()
+	var gaps []QuoteGap
+	for _, tier := range sortedKeys(byTier) {
+		repair, threshold := t.gaps.gapThreshold(tier)
+		found, err := findGaps(ctx, sqlDB, byTier[tier], now.Add(-t.gaps.lookback), threshold)
+		if err != nil {
+			return report, err
+		}
+		for i := range found {
+			found[i].Interval = repair.Name
+		}
+		gaps = append(gaps, found...)
+	}
+	report.Detected = len(gaps)
+
+	for i, gap := range gaps {
+		id, status, attempts, err := recordGap(ctx, sqlDB, gap)
+		if err != nil {
+			return report, err
+		}
+		if status == GapFilled || attempts >= maxGapAttempts {
+			report.Skipped++
+			continue
+		}
+
+		bf, err := t.runBackfill(ctx, sqlDB, BackfillRequest{
+			CmcID: gap.CmcID, From: gap.Start, To: gap.End, Interval: gap.Interval, Currency: gap.Currency,
+		}, report.CreditCount)
+		report.CreditCount += bf.CreditCount
+		gap.Points = bf.PointsWritten
+		gap.Err = err
+		if err == nil && gap.Points == 0 {
+			gap.Err = ErrGapNoData
+		}
+
+		status = GapFilled
+		var lastError any
+		if gap.Err != nil {
+			status = GapUnfilled
+			lastError = gap.Err.Error()
+		}
+		if _, dbErr := sqlDB.ExecContext(ctx, updateGapSQL, id, status, gap.Points, lastError); dbErr != nil {
+			return report, fmt.Errorf("failed to update gap for cmc_id %d %s: %w", gap.CmcID, gap.Currency, dbErr)
+		}
+		if status == GapFilled {
+			report.Filled = append(report.Filled, gap)
+		} else {
+			report.Unfilled = append(report.Unfilled, gap)
+		}
+
+		// Out of credits or shutting down, leave the remaining gaps for the next run
+		if errors.Is(err, ErrCreditBudgetExceeded) || ctx.Err() != nil {
+			stopErr := err
+			if stopErr == nil {
+				stopErr = ctx.Err()
+			}
+			for _, rest := range gaps[i+1:] {
+				rest.Err = stopErr
+				report.Unfilled = append(report.Unfilled, rest)
+			}
+			break
+		}
+	}
+
+	t.logger.Info("Gap repair finished", "detected", report.Detected, "filled", len(report.Filled),
+		"unfilled", len(report.Unfilled), "skipped", report.Skipped, "credit_count", report.CreditCount)
+	return report, nil
+}
+
+// findGaps returns the closed gaps longer than threshold in the history of the coins ids since from, including a gap
+// between the last point before from and the first point after it
+func findGaps(ctx context.Context, sqlDB *sql.DB, ids []string, from time.Time, threshold time.Duration) ([]QuoteGap, error) {
+	cmcIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		cmcID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidID, id)
+		}
+		cmcIDs = append(cmcIDs, cmcID)
+	}
+
+	rows, err := sqlDB.QueryContext(ctx, selectHistoryGapsSQL, pq.Array(cmcIDs), from, threshold.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history gaps: %w", err)
+	}
+	defer rows.Close()
+
+	var gaps []QuoteGap
+	for rows.Next() {
+		var gap QuoteGap
+		if err := rows.Scan(&gap.CmcID, &gap.Currency, &gap.Start, &gap.End); err != nil {
+			return nil, fmt.Errorf("failed to scan history gap: %w", err)
+		}
+		gap.Start = gap.Start.UTC()
+		gap.End = gap.End.UTC()
+		gaps = append(gaps, gap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history gaps: %w", err)
+	}
+	return gaps, nil
+}
+
+// recordGap records a detected gap and returns its id, status and number of repair attempts so far
+func recordGap(ctx context.Context, sqlDB *sql.DB, gap QuoteGap) (int64, string, int, error) {
+	var id int64
+	var status string
+	var attempts int
+	err := sqlDB.QueryRowContext(ctx, upsertGapSQL, gap.CmcID, gap.Currency, gap.Start, gap.End).Scan(&id, &status, &attempts)
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("failed to record gap for cmc_id %d %s: %w", gap.CmcID, gap.Currency, err)
+	}
+	return id, status, attempts, nil
+}
@@ -0,0 +1,95 @@
+package ticker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestGapThreshold tests the repair interval and min gap length per tier
+func TestGapThreshold(t *testing.T) {
+	params := buildGapParams(config.IntervalSettings{
+		TickerInterval: 2 * time.Minute,
+		GapTolerance:   2,
+		Tiers: []config.TierSettings{
+			{Name: "hot", Interval: 30 * time.Second},
+			{Name: "cold", Interval: 50 * time.Minute},
+			{Name: "rare", Interval: 48 * time.Hour},
+		},
+	})
+
+	tests := []struct {
+		tier      string
+		interval  string
+		threshold time.Duration
+	}{
+		{tier: "hot", interval: "5m", threshold: 10 * time.Minute},     // historical data is 5m at best
+		{tier: "cold", interval: "1h", threshold: 2 * time.Hour},       // smallest interval covering 50m
+		{tier: "rare", interval: "24h", threshold: 96 * time.Hour},     // longer than any historical interval
+		{tier: "unknown", interval: "5m", threshold: 10 * time.Minute}, // falls back to the ticker interval
+	}
+	for _, tt := range tests {
+		t.Run(tt.tier, func(t *testing.T) {
+			repair, threshold := params.gapThreshold(tt.tier)
+			if repair.Name != tt.interval || threshold != tt.threshold {
+				t.Errorf("gapThreshold(%s) = %s, %v, want %s, %v", tt.tier, repair.Name, threshold, tt.interval, tt.threshold)
+			}
+		})
+	}
+
+	t.Run("tolerance at least 1", func(t *testing.T) {
+		params := buildGapParams(config.IntervalSettings{GapTolerance: 0.5, TickerInterval: time.Hour})
+		if _, threshold := params.gapThreshold("normal"); threshold != time.Hour {
+			t.Errorf("Expected threshold of 1h, got %v", threshold)
+		}
+	})
+}
+
+// TestRepairGapsRequiresDatabase tests that RepairGaps refuses to run without a database
+func TestRepairGapsRequiresDatabase(t *testing.T) {
+	service := NewTickerService(&config.AppConfig{}, &fakeCoins{ids: []int{1}}, testLogger(), http.DefaultClient)
+
+	report, err := service.RepairGaps(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error without a database")
+	}
+	if report == nil || report.FinishedAt.IsZero() {
+		t.Errorf("Expected a finished report on error, got %+v", report)
+	}
+}
+
+// TestFindGapsPostgres tests that gaps reaching into the lookback window are found and open gaps are left out
+func TestFindGapsPostgres(t *testing.T) {
+	sqlDB := testPostgres(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, ago := range []time.Duration{20 * time.Hour, 10 * time.Hour, time.Hour, 50 * time.Minute, 10 * time.Minute} {
+		if _, err := writeQuotes(ctx, sqlDB, testCoins(now.Add(-ago), 1), coinInfoFull); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := writeQuotes(ctx, sqlDB, testCoins(now.Add(-5*time.Hour), 2), coinInfoFull); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	gaps, err := findGaps(ctx, sqlDB, []string{"1", "2"}, now.Add(-2*time.Hour), 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []QuoteGap{
+		{CmcID: 1, Currency: "USD", Start: now.Add(-10 * time.Hour), End: now.Add(-time.Hour)}, // started before the window
+		{CmcID: 1, Currency: "USD", Start: now.Add(-50 * time.Minute), End: now.Add(-10 * time.Minute)},
+	}
+	if len(gaps) != len(want) {
+		t.Fatalf("Expected gaps %+v, got %+v", want, gaps)
+	}
+	for i, gap := range gaps {
+		if gap.CmcID != want[i].CmcID || gap.Currency != want[i].Currency ||
+			!gap.Start.Equal(want[i].Start) || !gap.End.Equal(want[i].End) {
+			t.Errorf("Expected gap %+v, got %+v", want[i], gap)
+		}
+	}
+}
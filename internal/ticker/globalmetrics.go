@@ -0,0 +1,152 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Global market metrics (total market cap, BTC/ETH dominance, total 24h volume) from /v1/global-metrics/quotes/latest
+// are synced on their own interval (TICKER_GLOBAL_METRICS_INTERVAL) and appended to global_metrics, one row per
+// currency per upstream last_updated. The endpoint costs 1 credit per call per convert target.
+
+const insertGlobalMetricsSQL = `
+INSERT INTO global_metrics (currency, total_market_cap, total_volume_24h, total_volume_24h_reported,
+    altcoin_market_cap, altcoin_volume_24h, total_market_cap_change_24h, total_volume_24h_change_24h,
+    btc_dominance, eth_dominance, defi_market_cap, defi_volume_24h, stablecoin_market_cap, stablecoin_volume_24h,
+    derivatives_volume_24h, active_cryptocurrencies, active_exchanges, active_market_pairs, last_updated)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+ON CONFLICT ON CONSTRAINT unique_global_metrics DO NOTHING`
+
+// SyncGlobalMetrics fetches the global metrics once per convert group and appends one global_metrics row per
+// currency. Skips the write if the database is not connected. Returns the metrics with the quotes of every group.
+func (t *TickerService) SyncGlobalMetrics(ctx context.Context) (*GlobalMetrics, error) {
+	if t.globalURL == "" {
+		return nil, fmt.Errorf("global metrics: no global metrics URL configured")
+	}
+
+	var metrics *GlobalMetrics
+	credits := 0
+	for _, convert := range t.cmc.convert {
+		data, err := t.callGlobalMetrics(ctx, convert)
+		if err != nil {
+			t.logger.Error("failed to fetch global metrics", "error", err, convert.key, convert.String())
+			return nil, err
+		}
+		resp, err := t.DecodeGlobalMetrics(data)
+		if err != nil {
+			return nil, err
+		}
+		credits += resp.Status.CreditCount
+		if metrics == nil {
+			metrics = &resp.Data
+			continue
+		}
+		if metrics.Quote == nil {
+			metrics.Quote = make(map[string]GlobalQuote)
+		}
+		for currency, quote := range resp.Data.Quote {
+			metrics.Quote[currency] = quote
+		}
+	}
+	t.budget.record(t.now(), credits)
+
+	sqlDB := t.database()
+	if sqlDB == nil {
+		t.logger.Warn("Database not connected - skipping global metrics update", "currencies_count", len(metrics.Quote))
+		return metrics, nil
+	}
+	written, overflows, err := writeGlobalMetrics(ctx, sqlDB, metrics)
+	for _, o := range overflows {
+		t.logger.Warn("global metric does not fit numeric column", "currency", o.Currency, "field", o.Field, "value", o.Value)
+	}
+	if err != nil {
+		t.logger.Error("failed to update global metrics", "error", err)
+		return metrics, err
+	}
+	t.logger.Info("Global metrics synced", "rows_written", written, "credit_count", credits,
+		"last_updated", metrics.LastUpdated)
+	return metrics, nil
+}
+
+// writeGlobalMetrics appends one global_metrics row per currency in a single transaction. Values that do not fit
+// their column are stored as NULL and returned as overflows. Returns the number of new rows.
+func writeGlobalMetrics(ctx context.Context, sqlDB *sql.DB, metrics *GlobalMetrics) (written int, overflows []NumericOverflow, err error) {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, currency := range sortedKeys(metrics.Quote) {
+		quote := metrics.Quote[currency]
+		lastUpdated := quote.LastUpdated
+		if lastUpdated.IsZero() {
+			lastUpdated = metrics.LastUpdated
+		}
+		// The time series requires an upstream timestamp to dedupe on
+		if lastUpdated.IsZero() {
+			continue
+		}
+
+		r := &overflowRecorder{currency: currency}
+		res, err := tx.ExecContext(ctx, insertGlobalMetricsSQL,
+			currency,
+			r.fit("total_market_cap", quote.TotalMarketCap, amountNumeric),
+			r.fit("total_volume_24h", quote.TotalVolume24H, amountNumeric),
+			r.fit("total_volume_24h_reported", quote.TotalVolume24HReported, amountNumeric),
+			r.fit("altcoin_market_cap", quote.AltcoinMarketCap, amountNumeric),
+			r.fit("altcoin_volume_24h", quote.AltcoinVolume24H, amountNumeric),
+			r.fit("total_market_cap_yesterday_percentage_change", quote.TotalMarketCapYesterdayPercentageChange, percentNumeric),
+			r.fit("total_volume_24h_yesterday_percentage_change", quote.TotalVolume24HYesterdayPercentageChange, percentNumeric),
+			r.fit("btc_dominance", metrics.BtcDominance, percentNumeric),
+			r.fit("eth_dominance", metrics.EthDominance, percentNumeric),
+			r.fit("defi_market_cap", metrics.DefiMarketCap, amountNumeric),
+			r.fit("defi_volume_24h", metrics.DefiVolume24H, amountNumeric),
+			r.fit("stablecoin_market_cap", metrics.StablecoinMarketCap, amountNumeric),
+			r.fit("stablecoin_volume_24h", metrics.StablecoinVolume24H, amountNumeric),
+			r.fit("derivatives_volume_24h", metrics.DerivativesVolume24H, amountNumeric),
+			metrics.ActiveCryptocurrencies, metrics.ActiveExchanges, metrics.ActiveMarketPairs, lastUpdated,
+		)
+		overflows = append(overflows, r.overflows...)
+		if err != nil {
+			return 0, overflows, fmt.Errorf("failed to insert global_metrics for %s: %w", currency, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			written++
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, overflows, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return written, overflows, nil
+}
+
+// callGlobalMetrics gets the global metrics for one group of convert targets and returns a []byte of the JSON response
+func (t *TickerService) callGlobalMetrics(ctx context.Context, convert convertParam) ([]byte, error) {
+	q := url.Values{}
+	q.Add(convert.key, convert.String())
+	return t.cmc.rest.get(ctx, t.globalURL, q)
+}
+
+// DecodeGlobalMetrics decodes a global metrics JSON []byte into a GlobalMetricsResponse.
+// API errors are returned as *APIError like DecodeData.
+func (t *TickerService) DecodeGlobalMetrics(data []byte) (*GlobalMetricsResponse, error) {
+	var resp GlobalMetricsResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.logger.Error("failed to unmarshal global metrics response", "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	if err := checkStatus(resp.Status); err != nil {
+		t.logger.Error("Coinmarketcap API returned error", "error", err, "credit_count", resp.Status.CreditCount)
+		return nil, err
+	}
+	return &resp, nil
+}
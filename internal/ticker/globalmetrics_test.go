@@ -0,0 +1,56 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestSyncGlobalMetrics tests that the quotes of every convert group are merged (no database connected)
+func TestSyncGlobalMetrics(t *testing.T) {
+	var converts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		convert := r.URL.Query().Get("convert")
+		converts = append(converts, convert)
+		w.Write([]byte(`{"status":{"error_code":0,"credit_count":1},"data":{"active_cryptocurrencies":9000,
+			"btc_dominance":52.123456789,"eth_dominance":17.5,"last_updated":"2024-01-01T00:00:00.000Z",
+			"quote":{"` + convert + `":{"total_market_cap":1700000000000.123,"total_volume_24h":50000000000,
+			"last_updated":"2024-01-01T00:00:00.000Z"}}}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{GlobalURL: server.URL, Convert: []string{"USD", "EUR"}, MaxConvertPerCall: 1}}
+	service := NewTickerService(cfg, &fakeCoins{}, testLogger(), server.Client())
+
+	metrics, err := service.SyncGlobalMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(converts) != 2 {
+		t.Errorf("Expected one call per convert group, got %v", converts)
+	}
+	if len(metrics.Quote) != 2 || metrics.Quote["EUR"].TotalMarketCap != "1700000000000.123" {
+		t.Errorf("Expected USD and EUR quotes, got %+v", metrics.Quote)
+	}
+	if metrics.BtcDominance != "52.123456789" || metrics.ActiveCryptocurrencies != 9000 {
+		t.Errorf("Expected exact dominance and counts, got %+v", metrics)
+	}
+
+	t.Run("API error", func(t *testing.T) {
+		_, err := service.DecodeGlobalMetrics([]byte(`{"status":{"error_code":1006,"error_message":"plan"}}`))
+		if !errors.Is(err, ErrPlanLimit) {
+			t.Errorf("Expected ErrPlanLimit, got %v", err)
+		}
+	})
+
+	t.Run("no URL", func(t *testing.T) {
+		service := NewTickerService(&config.AppConfig{}, &fakeCoins{}, testLogger(), server.Client())
+		if _, err := service.SyncGlobalMetrics(context.Background()); err == nil {
+			t.Error("Expected an error without a global metrics URL")
+		}
+	})
+}
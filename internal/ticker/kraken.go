@@ -0,0 +1,377 @@
+package ticker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// Kraken spot API documentation: https://docs.kraken.com/api/docs/rest-api/get-ticker-information
+// TICKER_PROVIDER=kraken syncs exchange prices from the Kraken /0/public/Ticker endpoint. Tracked coins are mapped to
+// assets in KRAKEN_ASSETS (cmc_id:asset) and paired with every quote currency in KRAKEN_QUOTE_CURRENCIES.
+// Kraken keeps legacy asset codes for its oldest assets: X prefixed crypto (XXBT, XETH), Z prefixed fiat (ZUSD) and
+// XBT/XDG instead of BTC/DOGE. Pairs are requested by their alternative name (XBTUSD) but returned under the legacy
+// pair name (XXBTZUSD), newer pairs use the common codes in both (SOLUSD). The normalization below maps every code to
+// the common code so result pairs can be matched to the requested pairs and the CMC ID of their base asset.
+// The ticker fills the last price and the 24h volume, converted to the quote currency with the 24h volume weighted
+// average price. Kraken only reports the open of the current UTC day, not a rolling 24h open, so the 24h change is
+// left null. The ticker has no timestamp, quotes are stamped with the fetch time. Results are quotes only.
+// Kraken reports errors in the "error" array of an HTTP 200 response. One unknown pair fails the whole call, the pairs
+// of that call are then fetched one by one so a bad mapping only drops its own pair.
+
+// krakenMaxPairs is the max number of pairs per call (keeps the URL length low)
+const krakenMaxPairs = 50
+
+// krakenLegacyAssets maps the legacy Kraken asset codes to the common codes
+var krakenLegacyAssets = map[string]string{
+	"XBT": "BTC", "XXBT": "BTC", "XDG": "DOGE", "XXDG": "DOGE",
+	"XETH": "ETH", "XETC": "ETC", "XLTC": "LTC", "XMLN": "MLN", "XREP": "REP", "XXLM": "XLM", "XXMR": "XMR",
+	"XXRP": "XRP", "XZEC": "ZEC",
+	"ZAUD": "AUD", "ZCAD": "CAD", "ZEUR": "EUR", "ZGBP": "GBP", "ZJPY": "JPY", "ZUSD": "USD",
+}
+
+// krakenAltAssets maps the common codes Kraken does not use in alternative pair names to the Kraken codes
+var krakenAltAssets = map[string]string{"BTC": "XBT", "DOGE": "XDG"}
+
+// krakenPair is a trading pair of a tracked coin with common asset codes
+type krakenPair struct {
+	cmcID string
+	base  string
+	quote string // quote currency, stored as currency
+}
+
+// KrakenProvider implements QuoteProvider with the Kraken ticker endpoint.
+type KrakenProvider struct {
+	rest            *restClient
+	assets          map[string]string // CMC ID -> common asset code
+	quoteCurrencies []string          // common codes
+	now             func() time.Time
+	logger          *slog.Logger
+}
+
+// krakenResponse holds a Kraken response. Error holds "E" prefixed errors and "W" prefixed warnings.
+type krakenResponse struct {
+	Error  []string                `json:"error"`
+	Result map[string]krakenTicker `json:"result"`
+}
+
+// krakenTicker holds one pair of the /0/public/Ticker response. Values are [today, last 24 hours] arrays of JSON
+// strings, c is [price, lot volume] of the last trade.
+type krakenTicker struct {
+	Close  []Decimal `json:"c"`
+	Volume []Decimal `json:"v"` // in the base asset
+	VWAP   []Decimal `json:"p"`
+}
+
+// NewKrakenProvider creates a new instance of the KrakenProvider struct
+func NewKrakenProvider(kr config.KrakenSettings, client *http.Client, logger *slog.Logger) *KrakenProvider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if len(kr.Assets) == 0 {
+		logger.Warn("No Kraken assets provided - requires KRAKEN_ASSETS to map tracked coins")
+	}
+	quoteCurrencies := make([]string, 0, len(kr.QuoteCurrencies))
+	for _, q := range kr.QuoteCurrencies {
+		quoteCurrencies = append(quoteCurrencies, normalizeKrakenAsset(q))
+	}
+	if len(quoteCurrencies) == 0 {
+		quoteCurrencies = []string{"USD"}
+	}
+	assets := providerIDs(kr.Assets)
+	for id, asset := range assets {
+		assets[id] = normalizeKrakenAsset(asset)
+	}
+	return &KrakenProvider{
+		rest: &restClient{
+			provider: ProviderKraken,
+			baseURL:  kr.BaseURL,
+			apiError: krakenHTTPError,
+			limiter:  newRateLimiter(kr.RateLimit, time.Minute),
+			client:   client,
+			logger:   logger,
+		},
+		assets:          assets,
+		quoteCurrencies: quoteCurrencies,
+		now:             time.Now,
+		logger:          logger,
+	}
+}
+
+// Name returns the provider name
+func (k *KrakenProvider) Name() string {
+	return ProviderKraken
+}
+
+// EstimateCredits returns 0, Kraken calls are rate limited instead
+func (k *KrakenProvider) EstimateCredits(ids []string) int {
+	return 0
+}
+
+// FetchQuotes fetches the tickers of every pair of the mapped coins in chunks of krakenMaxPairs. A failing call is
+// reported in ChunkErrors and does not stop the other calls unless the error applies to every call.
+func (k *KrakenProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	pairs := make(map[string]krakenPair) // keyed by alternative pair name
+	var unmapped []string
+	for _, id := range ids {
+		base, ok := k.assets[id]
+		if !ok {
+			unmapped = append(unmapped, id)
+			continue
+		}
+		for _, quote := range k.quoteCurrencies {
+			pairs[krakenAltName(base, quote)] = krakenPair{cmcID: id, base: base, quote: quote}
+		}
+	}
+	quotes := &ProviderQuotes{Provider: ProviderKraken, Coins: make(map[string]CoinInfo), QuotesOnly: true}
+	if len(unmapped) > 0 {
+		quotes.ChunkErrors = append(quotes.ChunkErrors, ChunkError{IDs: unmapped, Err: ErrUnmappedCoin})
+	}
+
+	chunks := chunkStrings(sortedKeys(pairs), krakenMaxPairs)
+	var firstErr error
+	failed := 0
+	for _, chunk := range chunks {
+		if firstErr != nil && isFatalAPIError(firstErr) {
+			// Remaining calls would fail with the same error
+			failed++
+			quotes.ChunkErrors = append(quotes.ChunkErrors, krakenChunkError(chunk, pairs, firstErr))
+			continue
+		}
+		tickers, chunkErrs, err := k.fetchChunk(ctx, chunk, pairs)
+		quotes.ChunkErrors = append(quotes.ChunkErrors, chunkErrs...)
+		k.addTickers(tickers, pairs, quotes.Coins)
+		if err != nil {
+			if len(tickers) == 0 {
+				failed++
+			}
+			if firstErr == nil || isFatalAPIError(err) {
+				firstErr = err
+			}
+		}
+	}
+	if len(chunks) > 0 && failed == len(chunks) {
+		quotes.Coins = nil
+		return quotes, firstErr
+	}
+
+	k.logger.Info("Fetched quotes", "calls", len(chunks), "failed_calls", failed, "coins_count", len(quotes.Coins),
+		"unmapped_count", len(unmapped))
+	return quotes, nil
+}
+
+// fetchChunk fetches the tickers of altNames in one call. One unknown pair fails the whole call, the pairs are then
+// fetched one by one to keep the valid ones. Returns the failed calls and an error if every pair failed or the error
+// applies to every call.
+func (k *KrakenProvider) fetchChunk(ctx context.Context, altNames []string, pairs map[string]krakenPair) (map[string]krakenTicker, []ChunkError, error) {
+	tickers, err := k.fetchTickers(ctx, altNames)
+	if err == nil {
+		return tickers, nil, nil
+	}
+	if !errors.Is(err, ErrInvalidID) || len(altNames) == 1 {
+		return nil, []ChunkError{krakenChunkError(altNames, pairs, err)}, err
+	}
+
+	k.logger.Warn("Unknown pair in call - fetching pairs one by one", "pairs_count", len(altNames))
+	tickers = make(map[string]krakenTicker)
+	var chunkErrs []ChunkError
+	var firstErr error
+	for i, altName := range altNames {
+		t, err := k.fetchTickers(ctx, []string{altName})
+		if isFatalAPIError(err) {
+			// Remaining pairs would fail with the same error
+			chunkErrs = append(chunkErrs, krakenChunkError(altNames[i:], pairs, err))
+			return tickers, chunkErrs, err
+		}
+		if err != nil {
+			chunkErrs = append(chunkErrs, krakenChunkError([]string{altName}, pairs, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for name, ticker := range t {
+			tickers[name] = ticker
+		}
+	}
+	if len(tickers) == 0 {
+		return nil, chunkErrs, firstErr
+	}
+	return tickers, chunkErrs, nil
+}
+
+// fetchTickers calls /0/public/Ticker for altNames. Returns the tickers keyed by the pair name of the response.
+func (k *KrakenProvider) fetchTickers(ctx context.Context, altNames []string) (map[string]krakenTicker, error) {
+	q := url.Values{}
+	q.Add("pair", strings.Join(altNames, ","))
+	data, err := k.rest.get(ctx, "/0/public/Ticker", q)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp krakenResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		k.logger.Error("failed to unmarshal ticker response", "error", err)
+		return nil, decodeError(ProviderKraken, err)
+	}
+	if apiErr := krakenError(0, resp.Error); apiErr != nil {
+		k.logger.Error("Kraken API returned error", "error", apiErr)
+		return nil, apiErr
+	}
+	return resp.Result, nil
+}
+
+// addTickers adds the quote of every ticker to the coin of its pair. Response pair names are matched to the
+// requested pairs with matchKrakenPair.
+func (k *KrakenProvider) addTickers(tickers map[string]krakenTicker, pairs map[string]krakenPair, coins map[string]CoinInfo) {
+	now := CMCTime{Time: k.now().UTC()}
+	for name, t := range tickers {
+		pair, ok := matchKrakenPair(name, pairs)
+		if !ok {
+			k.logger.Warn("Unknown pair in ticker response", "pair", name)
+			continue
+		}
+		if len(t.Close) == 0 {
+			continue
+		}
+		var volume Decimal
+		if len(t.Volume) > 1 && len(t.VWAP) > 1 {
+			volume = t.Volume[1].Mul(t.VWAP[1], amountNumeric.scale)
+		}
+		coin := coins[pair.cmcID]
+		if coin.Quote == nil {
+			cmcID, _ := strconv.Atoi(pair.cmcID)
+			coin = CoinInfo{CmcID: cmcID, Symbol: pair.base, Quote: make(map[string]CoinQuote)}
+		}
+		coin.Quote[pair.quote] = CoinQuote{Price: t.Close[0], Volume24H: volume, LastUpdated: now}
+		coins[pair.cmcID] = coin
+	}
+}
+
+// normalizeKrakenAsset returns the common code of a Kraken asset code (XXBT, XBT -> BTC, ZUSD -> USD)
+func normalizeKrakenAsset(code string) string {
+	code = strings.ToUpper(code)
+	if common, ok := krakenLegacyAssets[code]; ok {
+		return common
+	}
+	return code
+}
+
+// krakenAltName returns the alternative pair name Kraken accepts for a pair of common codes (BTC, USD -> XBTUSD)
+func krakenAltName(base, quote string) string {
+	if alt, ok := krakenAltAssets[base]; ok {
+		base = alt
+	}
+	if alt, ok := krakenAltAssets[quote]; ok {
+		quote = alt
+	}
+	return base + quote
+}
+
+// matchKrakenPair returns the requested pair of a pair name from a Kraken response (keyed by alternative name, see
+// krakenAltName). The name is the alternative name (SOLUSD, XBTUSD, XTZUSD) or the legacy name (XXBTZUSD, USDTZUSD)
+// and has no separator, so every split into base and quote is tried against the requested pairs: splits into two
+// legacy codes first (XXBT + ZUSD), then the longest base first. Guessing the base from a code prefix instead
+// would split XTZUSD into XT + ZUSD.
+func matchKrakenPair(name string, pairs map[string]krakenPair) (krakenPair, bool) {
+	if pair, ok := pairs[name]; ok {
+		return pair, true
+	}
+	match := func(i int) (krakenPair, bool) {
+		base, quote := normalizeKrakenAsset(name[:i]), normalizeKrakenAsset(name[i:])
+		pair, ok := pairs[krakenAltName(base, quote)]
+		return pair, ok && pair.base == base && pair.quote == quote
+	}
+	for i := 1; i < len(name); i++ {
+		_, legacyBase := krakenLegacyAssets[name[:i]]
+		_, legacyQuote := krakenLegacyAssets[name[i:]]
+		if !legacyBase || !legacyQuote {
+			continue
+		}
+		if pair, ok := match(i); ok {
+			return pair, true
+		}
+	}
+	for i := len(name) - 1; i > 0; i-- {
+		if pair, ok := match(i); ok {
+			return pair, true
+		}
+	}
+	return krakenPair{}, false
+}
+
+// krakenChunkError returns the ChunkError of a failed call with the CMC ID's of its pairs
+func krakenChunkError(altNames []string, pairs map[string]krakenPair, err error) ChunkError {
+	seen := make(map[string]bool)
+	var ids, quotes []string
+	for _, altName := range altNames {
+		pair := pairs[altName]
+		if !seen[pair.cmcID] {
+			seen[pair.cmcID] = true
+			ids = append(ids, pair.cmcID)
+		}
+		if !seen["quote:"+pair.quote] {
+			seen["quote:"+pair.quote] = true
+			quotes = append(quotes, pair.quote)
+		}
+	}
+	return ChunkError{IDs: ids, Convert: strings.Join(quotes, ","), Err: err}
+}
+
+// krakenError maps the "E" prefixed errors of a Kraken response to an *APIError. Returns nil if errs only holds
+// warnings. status is the HTTP status code (0 for errors in a successful response).
+func krakenError(status int, errs []string) *APIError {
+	var messages []string
+	for _, e := range errs {
+		if strings.HasPrefix(e, "E") {
+			messages = append(messages, e)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return &APIError{
+		Provider: ProviderKraken,
+		Code:     status,
+		Message:  errorMessage(strings.Join(messages, ", ")),
+		Err:      classifyKrakenError(messages[0]),
+	}
+}
+
+// krakenHTTPError maps a failed call to an *APIError. Uses the error array if the body is a Kraken response,
+// the HTTP status code otherwise.
+func krakenHTTPError(status int, body []byte) *APIError {
+	var resp krakenResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if apiErr := krakenError(status, resp.Error); apiErr != nil {
+			return apiErr
+		}
+	}
+	return newHTTPError(ProviderKraken, status, errorMessage(string(body)))
+}
+
+// classifyKrakenError returns the sentinel error for a Kraken error ("<severity><category>:<message>").
+// EQuery:Unknown asset pair is returned for pairs Kraken does not list.
+// EAPI:Rate limit exceeded, EGeneral:Too many requests and EService:Throttled are rate limits.
+// EAPI:Invalid key and EGeneral:Permission denied are authentication errors (private endpoints only).
+func classifyKrakenError(message string) error {
+	switch {
+	case strings.HasPrefix(message, "EQuery:Unknown asset pair"):
+		return ErrInvalidID
+	case strings.HasPrefix(message, "EAPI:Rate limit exceeded"),
+		strings.HasPrefix(message, "EGeneral:Too many requests"),
+		strings.HasPrefix(message, "EService:Throttled"):
+		return ErrRateLimited
+	case strings.HasPrefix(message, "EAPI:Invalid key"), strings.HasPrefix(message, "EGeneral:Permission denied"):
+		return ErrUnauthorized
+	}
+	return ErrAPI
+}
@@ -0,0 +1,191 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestKrakenPairNormalization tests matching of legacy and common Kraken pair names to the requested pairs
+func TestKrakenPairNormalization(t *testing.T) {
+	pairs := make(map[string]krakenPair)
+	for _, p := range [][2]string{
+		{"BTC", "USD"}, {"BTC", "USDT"}, {"ETH", "EUR"}, {"ETH", "BTC"}, {"SOL", "USD"}, {"DOGE", "USD"}, {"USDT", "USD"},
+		{"XTZ", "USD"}, {"XT", "USD"}, {"ETH", "USD"}, {"ETHW", "USD"},
+	} {
+		pairs[krakenAltName(p[0], p[1])] = krakenPair{cmcID: p[0], base: p[0], quote: p[1]}
+	}
+	tests := map[string][2]string{
+		"XXBTZUSD": {"BTC", "USD"},
+		"XBTUSDT":  {"BTC", "USDT"},
+		"XETHZEUR": {"ETH", "EUR"},
+		"XETHXXBT": {"ETH", "BTC"},
+		"SOLUSD":   {"SOL", "USD"},
+		"XDGUSD":   {"DOGE", "USD"},
+		"USDTZUSD": {"USDT", "USD"},
+		"XTZUSD":   {"XTZ", "USD"}, // not XT + ZUSD
+		"XTUSD":    {"XT", "USD"},
+		"XETHZUSD": {"ETH", "USD"},
+		"ETHWUSD":  {"ETHW", "USD"}, // ETH is a prefix of ETHW
+	}
+	for name, want := range tests {
+		pair, ok := matchKrakenPair(name, pairs)
+		if !ok || pair.base != want[0] || pair.quote != want[1] {
+			t.Errorf("%s: expected %s/%s, got %s/%s (%v)", name, want[0], want[1], pair.base, pair.quote, ok)
+		}
+	}
+	for _, name := range []string{"SOLGBP", "XBTTUSD"} {
+		if pair, ok := matchKrakenPair(name, pairs); ok {
+			t.Errorf("%s: expected no match for a pair not requested, got %s/%s", name, pair.base, pair.quote)
+		}
+	}
+	if got := krakenAltName(normalizeKrakenAsset("xxbt"), "USD"); got != "XBTUSD" {
+		t.Errorf("Expected XBTUSD, got %s", got)
+	}
+}
+
+// TestKrakenPrefixAssets tests that tickers of assets whose code is a prefix of another asset go to the right coin
+func TestKrakenPrefixAssets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":[],"result":{"XTZUSD":{"c":["0.75","1"]},"XTUSD":{"c":["0.02","1"]}}}`))
+	}))
+	defer server.Close()
+
+	provider := NewKrakenProvider(config.KrakenSettings{
+		BaseURL: server.URL, Assets: map[int]string{2011: "XTZ", 9999: "XT"}, QuoteCurrencies: []string{"ZUSD"},
+	}, server.Client(), testLogger())
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"2011", "9999"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if xtz := quotes.Coins["2011"]; xtz.Symbol != "XTZ" || xtz.Quote["USD"].Price != "0.75" {
+		t.Errorf("Expected the XTZUSD ticker for XTZ, got %+v", xtz)
+	}
+	if xt := quotes.Coins["9999"]; xt.Symbol != "XT" || xt.Quote["USD"].Price != "0.02" {
+		t.Errorf("Expected the XTUSD ticker for XT, got %+v", xt)
+	}
+}
+
+// TestKrakenTicker tests mapping of the ticker response into quotes keyed by CMC ID
+func TestKrakenTicker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/Ticker" || r.URL.Query().Get("pair") != "ETHUSD,SOLUSD,XBTUSD" {
+			t.Errorf("Unexpected request: %s %s", r.URL.Path, r.URL.Query().Get("pair"))
+		}
+		w.Write([]byte(`{"error":[],"result":{
+			"XXBTZUSD":{"a":["67187.40000","1","1.000"],"c":["67187.30000","0.00100000"],"v":["812.5","2000.5"],
+				"p":["67000.0","66000.0"],"t":[1000,2000],"o":"66500.00000"},
+			"XETHZUSD":{"c":["3500.50","0.1"],"v":["10","20"],"p":["3400","3450"]},
+			"SOLUSD":{"c":["180.12","1"],"v":["100","200"],"p":["175","178.5"]}}}`))
+	}))
+	defer server.Close()
+
+	provider := NewKrakenProvider(config.KrakenSettings{
+		BaseURL: server.URL, Assets: map[int]string{1: "XBT", 1027: "eth", 5994: "SOL"}, QuoteCurrencies: []string{"ZUSD"},
+	}, server.Client(), testLogger())
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "1027", "5994", "2010"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !quotes.QuotesOnly || len(quotes.Coins) != 3 {
+		t.Fatalf("Expected 3 quotes only coins, got %+v", quotes.Coins)
+	}
+	btc := quotes.Coins["1"]
+	usd := btc.Quote["USD"]
+	if btc.Symbol != "BTC" || usd.Price != "67187.30000" || usd.Volume24H != "132033000.00000000" {
+		t.Errorf("Expected BTC price and volume in USD, got %+v", btc)
+	}
+	if !usd.PercentChange24h.IsNull() || !usd.LastUpdated.Equal(now) {
+		t.Errorf("Expected no 24h change and the fetch time, got %+v", usd)
+	}
+	if quotes.Coins["1027"].Quote["USD"].Price != "3500.50" || quotes.Coins["5994"].Quote["USD"].Price != "180.12" {
+		t.Errorf("Expected ETH and SOL quotes, got %+v", quotes.Coins)
+	}
+	if len(quotes.ChunkErrors) != 1 || !errors.Is(quotes.ChunkErrors[0], ErrUnmappedCoin) {
+		t.Errorf("Expected 2010 to be unmapped, got %v", quotes.ChunkErrors)
+	}
+}
+
+// TestKrakenErrors tests that the error array is returned as typed errors and unknown pairs only drop their own pair
+func TestKrakenErrors(t *testing.T) {
+	t.Run("unknown pair", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.Query().Get("pair"), "FOOUSD") {
+				w.Write([]byte(`{"error":["EQuery:Unknown asset pair"]}`))
+				return
+			}
+			w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"c":["67187.3","0.001"],"v":["1","2"],"p":["1","2"]}}}`))
+		}))
+		defer server.Close()
+		provider := NewKrakenProvider(config.KrakenSettings{
+			BaseURL: server.URL, Assets: map[int]string{1: "BTC", 99: "FOO"}, QuoteCurrencies: []string{"USD"},
+		}, server.Client(), testLogger())
+
+		quotes, err := provider.FetchQuotes(context.Background(), []string{"1", "99"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if quotes.Coins["1"].Quote["USD"].Price != "67187.3" {
+			t.Errorf("Expected the BTC quote, got %+v", quotes.Coins)
+		}
+		var apiErr *APIError
+		if len(quotes.ChunkErrors) != 1 || quotes.ChunkErrors[0].IDs[0] != "99" || !errors.As(quotes.ChunkErrors[0], &apiErr) {
+			t.Fatalf("Expected one *APIError for 99, got %v", quotes.ChunkErrors)
+		}
+		if !errors.Is(apiErr, ErrInvalidID) || apiErr.Provider != ProviderKraken || apiErr.Message != "EQuery:Unknown asset pair" {
+			t.Errorf("Expected Kraken ErrInvalidID, got %v", apiErr)
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error":["EAPI:Rate limit exceeded"]}`))
+		}))
+		defer server.Close()
+		provider := NewKrakenProvider(config.KrakenSettings{
+			BaseURL: server.URL, Assets: map[int]string{1: "BTC"}, QuoteCurrencies: []string{"USD"},
+		}, server.Client(), testLogger())
+
+		if _, err := provider.FetchQuotes(context.Background(), []string{"1"}); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+	})
+
+	t.Run("classify", func(t *testing.T) {
+		tests := map[string]error{
+			"EGeneral:Too many requests": ErrRateLimited,
+			"EService:Throttled:1711":    ErrRateLimited,
+			"EAPI:Invalid key":           ErrUnauthorized,
+			"EGeneral:Invalid arguments": ErrAPI,
+			"EService:Unavailable":       ErrAPI,
+		}
+		for msg, want := range tests {
+			if got := classifyKrakenError(msg); got != want {
+				t.Errorf("%s: expected %v, got %v", msg, want, got)
+			}
+		}
+		if krakenError(0, []string{"WGeneral:Deprecated"}) != nil {
+			t.Error("Expected warnings to be ignored")
+		}
+		long := make([]string, maxErrorBody)
+		for i := range long {
+			long[i] = "EQuery:Unknown asset pair"
+		}
+		if err := krakenError(0, long); len(err.Message) != maxErrorBody {
+			t.Errorf("Expected the joined errors truncated to %d bytes, got %d", maxErrorBody, len(err.Message))
+		}
+		if err := krakenHTTPError(http.StatusServiceUnavailable, []byte(`{"error":["EService:Busy"]}`)); err.Code != 503 || err.Message != "EService:Busy" {
+			t.Errorf("Expected the error array of a failed call, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package ticker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Listings mode (CMC_SYNC_MODE=listings) syncs the top coins from /v1/cryptocurrency/listings/latest
+// (ex. the top 200 by market cap) instead of the tracked coin ID's. Whatever comes back is persisted through the same
+// validation and write path as quotes mode and coins entering the set get coin_info rows from the upsert.
+
+// Sync modes selectable in CMC_SYNC_MODE
+const (
+	SyncModeQuotes   = "quotes"
+	SyncModeListings = "listings"
+)
+
+// listingsParams holds the listings query (CMC_LISTINGS_*)
+type listingsParams struct {
+	url   string
+	start int // 1 based rank offset
+	limit int
+	sort  string
+}
+
+// syncListings fetches the configured listings from the provider and persists every returned coin
+func (t *TickerService) syncListings(ctx context.Context) (*SyncReport, error) {
+	report := newSyncReport(t.now(), t.provider.Name())
+	defer func() {
+		report.finish(t.now())
+		t.budget.record(report.FinishedAt, report.CreditCount)
+	}()
+
+	lp, ok := t.provider.(ListingsProvider)
+	if !ok {
+		return report, fmt.Errorf("provider %s does not support listings mode", t.provider.Name())
+	}
+	quotes, ids, err := lp.FetchListings(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.CoinsRequested = len(ids)
+	report.setUpstream(quotes)
+	return report, t.persist(ctx, report, ids, quotes)
+}
+
+// fetchListings calls the listings endpoint once per convert group and merges the results into one CMCResponse.
+// Returns the CMC ID's in listing order.
+func (c *CMCProvider) fetchListings(ctx context.Context) (*CMCResponse, []string, error) {
+	merged := &CMCResponse{Data: make(map[string]CoinInfo)}
+	var ids []string
+	for _, convert := range c.convert {
+		data, err := c.callListings(ctx, convert)
+		if err != nil {
+			c.logger.Error("failed to fetch listings", "error", err, convert.key, convert.String())
+			return nil, nil, err
+		}
+		resp, err := c.DecodeListings(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, coin := range resp.Data {
+			id := strconv.Itoa(coin.CmcID)
+			if _, ok := merged.Data[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		mergeResponse(merged, resp)
+	}
+	c.logger.Info("Fetched listings", "coins_count", len(ids), "credit_count", merged.Status.CreditCount)
+	return merged, ids, nil
+}
+
+// callListings gets the listings for one group of convert targets and returns a []byte of the JSON response
+func (c *CMCProvider) callListings(ctx context.Context, convert convertParam) ([]byte, error) {
+	q := url.Values{}
+	q.Add("start", strconv.Itoa(max(c.listings.start, 1)))
+	q.Add("limit", strconv.Itoa(c.listings.limit))
+	if c.listings.sort != "" {
+		q.Add("sort", c.listings.sort)
+	}
+	q.Add(convert.key, convert.String())
+	if len(c.aux) > 0 {
+		q.Add("aux", strings.Join(c.aux, ","))
+	}
+	return c.rest.get(ctx, c.listings.url, q)
+}
+
+// DecodeListings decodes a listings JSON []byte into a CMCResponse keyed by CMC ID.
+// API errors are returned as *APIError like DecodeData.
+func (c *CMCProvider) DecodeListings(data []byte) (*CMCResponse, error) {
+	var listings ListingsResponse
+	if err := json.Unmarshal(data, &listings); err != nil {
+		c.logger.Error("failed to unmarshal listings response", "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	if err := checkStatus(listings.Status); err != nil {
+		c.logger.Error("Coinmarketcap API returned error", "error", err, "credit_count", listings.Status.CreditCount)
+		return nil, err
+	}
+
+	resp := &CMCResponse{Status: listings.Status, Data: make(map[string]CoinInfo, len(listings.Data))}
+	for _, coin := range listings.Data {
+		resp.Data[strconv.Itoa(coin.CmcID)] = coin
+	}
+	return resp, nil
+}
@@ -0,0 +1,45 @@
+package ticker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestSyncListings tests that listings mode queries the listings endpoint and persists every returned coin
+func TestSyncListings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("start") != "1" || q.Get("limit") != "2" || q.Get("sort") != "market_cap" {
+			t.Errorf("Unexpected listings query: %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"status":{"error_code":0,"credit_count":1},"data":[
+			{"id":1,"symbol":"BTC","cmc_rank":1,"tags":["mineable"],"quote":{"USD":{"price":50000}}},
+			{"id":1027,"symbol":"ETH","cmc_rank":2,"quote":{"USD":{"price":3000}}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{
+		ListingsURL:   server.URL,
+		SyncMode:      SyncModeListings,
+		ListingsStart: 1,
+		ListingsLimit: 2,
+		ListingsSort:  "market_cap",
+	}}
+	// No tracked coins: listings mode does not use them
+	service := NewTickerService(cfg, &fakeCoins{}, testLogger(), server.Client())
+
+	report, err := service.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.CoinsRequested != 2 || report.CoinsReturned != 2 || len(report.Outcomes) != 2 {
+		t.Errorf("Expected 2 coins in report, got %+v", report)
+	}
+	if report.CreditCount != 1 {
+		t.Errorf("Expected credit count 1, got %d", report.CreditCount)
+	}
+}
@@ -0,0 +1,107 @@
+package ticker
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Every sync produces one CoinOutcome per requested coin so a single bad ID or missing entry in ProviderQuotes.Coins
+// is visible to the caller. Healthy coins are persisted even when others fail.
+
+// OutcomeStatus describes what happened to a coin during a sync.
+type OutcomeStatus string
+
+const (
+	OutcomeUpdated     OutcomeStatus = "updated"      // quote written to the database
+	OutcomeUnchanged   OutcomeStatus = "unchanged"    // nothing written (no new data, or ErrNoCoinInfo)
+	OutcomeMissing     OutcomeStatus = "missing"      // requested but not returned by upstream
+	OutcomeInvalid     OutcomeStatus = "invalid"      // returned by upstream but failed validation
+	OutcomeWriteFailed OutcomeStatus = "write_failed" // valid but the database write failed
+)
+
+// Errors recorded in CoinOutcome.Err
+var (
+	ErrMissingUpstream = errors.New("coin missing from upstream response")
+	ErrNoQuotes        = errors.New("coin has no quotes")
+	// ErrNoCoinInfo is recorded for quotes only coins without symbol (CoinGecko simple) that have no coin_info row yet
+	ErrNoCoinInfo = errors.New("coin_info row missing and provider has no coin fields")
+)
+
+// CoinOutcome holds the result of a sync for a single coin.
+type CoinOutcome struct {
+	CmcID  int
+	Symbol string
+	Status OutcomeStatus
+	Err    error // reason for missing, invalid, write_failed and skipped unchanged outcomes
+	Stale  bool  // upstream last_updated has not advanced within the staleness window
+}
+
+// MissingCoins returns the CMC ID's of coins that were requested but not returned by upstream.
+func MissingCoins(outcomes []CoinOutcome) []int {
+	var ids []int
+	for _, o := range outcomes {
+		if o.Status == OutcomeMissing {
+			ids = append(ids, o.CmcID)
+		}
+	}
+	return ids
+}
+
+// StaleCoins returns the CMC ID's of coins flagged as stale.
+func StaleCoins(outcomes []CoinOutcome) []int {
+	var ids []int
+	for _, o := range outcomes {
+		if o.Stale {
+			ids = append(ids, o.CmcID)
+		}
+	}
+	return ids
+}
+
+// CountOutcomes returns the number of outcomes per status.
+func CountOutcomes(outcomes []CoinOutcome) map[OutcomeStatus]int {
+	counts := make(map[OutcomeStatus]int)
+	for _, o := range outcomes {
+		counts[o.Status]++
+	}
+	return counts
+}
+
+// splitResponse checks every requested ID against the provider response. Returns outcomes for missing and invalid
+// coins and the coins that can be written to the database. Coins in a failed chunk are missing with the chunk error.
+func splitResponse(ids []string, quotes *ProviderQuotes) (valid map[string]CoinInfo, outcomes []CoinOutcome) {
+	failedChunk := make(map[string]error)
+	for _, c := range quotes.ChunkErrors {
+		for _, id := range c.IDs {
+			failedChunk[id] = c
+		}
+	}
+
+	valid = make(map[string]CoinInfo)
+	for _, id := range ids {
+		coin, ok := quotes.Coins[id]
+		if !ok {
+			cmcID, _ := strconv.Atoi(id)
+			err := ErrMissingUpstream
+			if chunkErr, failed := failedChunk[id]; failed {
+				err = chunkErr
+			}
+			outcomes = append(outcomes, CoinOutcome{CmcID: cmcID, Status: OutcomeMissing, Err: err})
+			continue
+		}
+		if err := validateCoin(coin); err != nil {
+			outcomes = append(outcomes, CoinOutcome{CmcID: coin.CmcID, Symbol: coin.Symbol, Status: OutcomeInvalid, Err: err})
+			continue
+		}
+		valid[id] = coin
+	}
+	return valid, outcomes
+}
+
+// validateCoin checks that a coin has the data required to be written to the database.
+func validateCoin(coin CoinInfo) error {
+	if len(coin.Quote) == 0 {
+		return ErrNoQuotes
+	}
+	return nil
+}
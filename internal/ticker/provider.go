@@ -0,0 +1,241 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// Sync gets quotes from a QuoteProvider so data vendors can be swapped without touching validation or storage.
+// Coins are identified by CMC ID (tracked_coins.cmc_id) and every provider maps them to its own identifiers.
+// Providers return ProviderQuotes holding CoinInfo and CoinQuote, the canonical coin and quote model that maps 1:1 to
+// the coin_info and coin_quote columns. Fields a provider does not supply are left null.
+// The provider is selected by name in TICKER_PROVIDER and configured in its own section of AppConfig.
+// Providers other than CMC map CMC ID's to their own identifiers with an ID mapping in their settings, rate limit their
+// calls with a rateLimiter and report failed calls as *APIError (see errors.go).
+// Historical backfill, gap repair and global metrics are CMC only and always use the CMC settings.
+
+// Quote provider names selectable in TICKER_PROVIDER
+const (
+	ProviderCMC       = "cmc"
+	ProviderCoinGecko = "coingecko"
+	ProviderBinance   = "binance"
+	ProviderCoinbase  = "coinbase"
+	ProviderKraken    = "kraken"
+)
+
+// ErrUnmappedCoin is recorded for tracked coins without an identifier in the provider ID mapping
+var ErrUnmappedCoin = errors.New("coin not mapped to a provider identifier")
+
+// maxErrorBody is the max length of a response body kept as *APIError message
+const maxErrorBody = 256
+
+// QuoteProvider fetches the latest quotes of coins from one upstream data vendor.
+// FetchQuotes returns the quotes of the coins ids (CMC ID's). On error the returned ProviderQuotes holds the failed
+// calls only. EstimateCredits returns the upstream credits a fetch of ids would cost (0 if the vendor has no credits).
+type QuoteProvider interface {
+	Name() string
+	FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error)
+	EstimateCredits(ids []string) int
+}
+
+// ListingsProvider is implemented by providers that can sync the top coins instead of the tracked coins
+// (CMC_SYNC_MODE=listings). FetchListings also returns the CMC ID's in listing order.
+type ListingsProvider interface {
+	FetchListings(ctx context.Context) (*ProviderQuotes, []string, error)
+}
+
+// ProviderQuotes holds the vendor neutral result of a provider fetch. Coins map key is the CMC ID.
+type ProviderQuotes struct {
+	Provider    string
+	Coins       map[string]CoinInfo
+	CreditCount int           // upstream credits summed over all calls (0 if the vendor has no credits)
+	Elapsed     time.Duration // upstream elapsed summed over all calls (0 if the vendor does not report it)
+	ChunkErrors []ChunkError  // calls that failed, the coins of the other calls are still returned
+	// Coins only hold CmcID, Symbol and quotes. coin_info rows are created for new coins but never updated so the
+	// coin fields kept by other providers are not overwritten with nulls. Coins without a Symbol are only written if
+	// their coin_info row exists, the others are skipped with ErrNoCoinInfo.
+	QuotesOnly bool
+	// Coins hold name, symbol, supplies and last_updated but none of the CMC only fields (slug, cmc_rank, tags, ...).
+	// Only those coin_info columns are updated, the CMC only columns keep their stored values.
+	MarketFields bool
+}
+
+// providerFactory creates a QuoteProvider from the app configuration
+type providerFactory func(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider
+
+// quoteProviders maps the provider names to their constructors
+var quoteProviders = map[string]providerFactory{
+	ProviderCMC: func(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider {
+		return NewCMCProvider(app.CMC, client, logger)
+	},
+	ProviderCoinGecko: func(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider {
+		return NewCoinGeckoProvider(app.CoinGecko, client, logger)
+	},
+	ProviderBinance: func(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider {
+		return NewBinanceProvider(app.Binance, client, logger)
+	},
+	ProviderCoinbase: func(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider {
+		return NewCoinbaseProvider(app.Coinbase, client, logger)
+	},
+	ProviderKraken: func(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider {
+		return NewKrakenProvider(app.Kraken, client, logger)
+	},
+}
+
+// newQuoteProvider returns the provider selected in app.Provider.Name. Falls back to CMC if the name is empty or
+// unknown.
+func newQuoteProvider(app *config.AppConfig, client *http.Client, logger *slog.Logger) QuoteProvider {
+	name := app.Provider.Name
+	factory, ok := quoteProviders[name]
+	if !ok {
+		if name != "" {
+			logger.Warn("Unknown quote provider - using CMC", "provider", name)
+		}
+		name, factory = ProviderCMC, quoteProviders[ProviderCMC]
+	}
+	return factory(app, client, logger.With("provider", name))
+}
+
+// providerIDs converts an ID mapping from the settings (CMC ID -> provider identifier) to the CMC ID's used as
+// query parameter values
+func providerIDs(ids map[int]string) map[string]string {
+	mapped := make(map[string]string, len(ids))
+	for cmcID, id := range ids {
+		mapped[strconv.Itoa(cmcID)] = id
+	}
+	return mapped
+}
+
+// mapIDs returns the CMC ID of every requested coin keyed by its provider identifier and the ids without a mapping
+func mapIDs(mapping map[string]string, ids []string) (map[string]string, []string) {
+	byProviderID := make(map[string]string, len(ids))
+	var unmapped []string
+	for _, id := range ids {
+		providerID, ok := mapping[id]
+		if !ok {
+			unmapped = append(unmapped, id)
+			continue
+		}
+		byProviderID[providerID] = id
+	}
+	return byProviderID, unmapped
+}
+
+// rateLimiter spaces calls evenly so at most limit calls start per period. Safe for concurrent use.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // 0 disables the limit
+	next     time.Time     // earliest start of the next call
+}
+
+// newRateLimiter creates a new rate limiter of limit calls per period. A limit of 0 disables the limiter.
+func newRateLimiter(limit int, period time.Duration) *rateLimiter {
+	if limit <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: period / time.Duration(limit)}
+}
+
+// wait blocks until the next call is allowed or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil || l.interval <= 0 {
+		return err
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// restClient sends the rate limited GET requests of a provider with a public REST API
+type restClient struct {
+	provider string
+	baseURL  string
+	header   http.Header                             // headers set on every request (ex. API key)
+	apiError func(status int, body []byte) *APIError // maps error responses, newHTTPError if nil
+	limiter  *rateLimiter
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+// get waits for the rate limiter, sends a GET request to baseURL + path and returns the response body.
+// Responses other than 2xx are returned as *APIError with the HTTP status code.
+func (r *restClient) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	if err := r.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(r.baseURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Accept", "application/json")
+	for key, values := range r.header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Error("HTTP request failed", "error", err, "url", req.URL.String())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		r.logger.Error("Provider API returned error", "status", resp.Status, "url", req.URL.String())
+		if r.apiError != nil {
+			return nil, r.apiError(resp.StatusCode, body)
+		}
+		return nil, newHTTPError(r.provider, resp.StatusCode, errorMessage(string(body)))
+	}
+	r.logger.Info("HTTP request successful", "status", resp.Status, "url", req.URL.String())
+	return body, nil
+}
+
+// errorMessage trims msg and truncates it to maxErrorBody bytes on a rune boundary for use as *APIError message
+func errorMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	if len(msg) > maxErrorBody {
+		cut := maxErrorBody
+		for cut > 0 && !utf8.RuneStart(msg[cut]) {
+			cut--
+		}
+		msg = msg[:cut]
+	}
+	return msg
+}
+
+// decodeError wraps a JSON unmarshal error of a provider response in ErrDecode
+func decodeError(provider string, err error) error {
+	return fmt.Errorf("%s: %w: %v", provider, ErrDecode, err)
+}
@@ -0,0 +1,100 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// fakeProvider implements QuoteProvider with fixed coins
+type fakeProvider struct {
+	coins map[string]CoinInfo
+	err   error
+	ids   []string // ids of the last fetch
+}
+
+func (f *fakeProvider) Name() string                     { return "fake" }
+func (f *fakeProvider) EstimateCredits(ids []string) int { return 0 }
+func (f *fakeProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	f.ids = ids
+	if f.err != nil {
+		return &ProviderQuotes{Provider: "fake", ChunkErrors: []ChunkError{{IDs: ids, Err: f.err}}}, f.err
+	}
+	return &ProviderQuotes{Provider: "fake", Coins: f.coins}, nil
+}
+
+// TestNewQuoteProvider tests provider selection by name
+func TestNewQuoteProvider(t *testing.T) {
+	tests := map[string]string{
+		"": ProviderCMC, "unknown": ProviderCMC, ProviderCMC: ProviderCMC, ProviderCoinGecko: ProviderCoinGecko,
+		ProviderBinance: ProviderBinance, ProviderCoinbase: ProviderCoinbase, ProviderKraken: ProviderKraken,
+	}
+	for name, want := range tests {
+		app := &config.AppConfig{Provider: config.ProviderSettings{Name: name}}
+		if got := newQuoteProvider(app, http.DefaultClient, testLogger()).Name(); got != want {
+			t.Errorf("provider %q: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+// TestSyncProvider tests that Sync only uses the QuoteProvider
+func TestSyncProvider(t *testing.T) {
+	provider := &fakeProvider{coins: map[string]CoinInfo{
+		"1": {CmcID: 1, Symbol: "BTC", Quote: map[string]CoinQuote{"USD": {Price: "50000"}}},
+	}}
+	service := NewTickerService(&config.AppConfig{}, &fakeCoins{ids: []int{1, 1027}}, testLogger(), nil)
+	service.provider = provider
+
+	report, err := service.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(provider.ids) != 2 {
+		t.Errorf("Expected the tracked coins to be fetched, got %v", provider.ids)
+	}
+	if report.Provider != "fake" || report.CoinsReturned != 1 || report.CoinsFailed != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	t.Run("provider error", func(t *testing.T) {
+		provider.err = ErrRateLimited
+		report, err := service.Sync(context.Background())
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+		if len(report.ChunkErrors) != 1 {
+			t.Errorf("Expected the failed call in the report, got %+v", report.ChunkErrors)
+		}
+	})
+}
+
+// TestRateLimiter tests that calls are spaced by the limit and that waiting stops when the context is done
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100, time.Second) // one call every 10ms
+	start := time.Now()
+	for range 3 {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected 3 calls to take at least 20ms, took %v", elapsed)
+	}
+
+	limiter = newRateLimiter(1, time.Hour)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("Expected the first call to start right away, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if err := newRateLimiter(0, time.Second).wait(context.Background()); err != nil {
+		t.Errorf("Expected disabled limiter to never wait, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package ticker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Refresh fetches and persists specific coins right away instead of waiting for the next TickerInterval tick
+// (ex. right after adding a coin or when a user reports a wrong price).
+// Every sync registers as a syncRun. A refresh whose coins are all covered by a sync already in progress waits for
+// that sync and returns its report instead of calling upstream again. A refresh is refused with
+// ErrCreditBudgetExceeded if its estimated credits would exceed the credit budget. The estimate is reserved before
+// the upstream call and replaced with the credits reported by upstream afterwards.
+
+// syncRun is a sync in progress. done is closed once report and err are set.
+type syncRun struct {
+	ids    map[string]bool
+	done   chan struct{}
+	report *SyncReport
+	err    error
+}
+
+// covers returns true if the run fetches every id
+func (r *syncRun) covers(ids []string) bool {
+	for _, id := range ids {
+		if !r.ids[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Refresh fetches and persists only the given coins. Coalesces with a sync already in progress that covers the coins.
+func (t *TickerService) Refresh(ctx context.Context, cmcIDs ...int) (*SyncReport, error) {
+	if len(cmcIDs) == 0 {
+		return nil, fmt.Errorf("refresh: no coins requested")
+	}
+	ids := make([]string, 0, len(cmcIDs))
+	seen := make(map[string]bool)
+	for _, id := range cmcIDs {
+		if s := strconv.Itoa(id); !seen[s] {
+			seen[s] = true
+			ids = append(ids, s)
+		}
+	}
+
+	// Join a sync in progress that already fetches these coins, or register this refresh so later ones can join it
+	run, joined := t.claimRun(ids)
+	if joined {
+		t.logger.Info("Refresh joined sync in progress", "cmc_ids", cmcIDs)
+		select {
+		case <-run.done:
+			return filterReport(run.report, ids), run.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// Reserve the estimated credits or refuse to run if the refresh would exceed the credit budget. Refreshes that
+	// joined this one are refused too.
+	credits := t.provider.EstimateCredits(ids)
+	reservation, err := t.budget.reserve(t.now(), credits)
+	if err != nil {
+		t.logger.Warn("Refresh refused", "cmc_ids", cmcIDs, "estimated_credits", credits, "error", err)
+		err = fmt.Errorf("refresh of %d coins (%d credits): %w", len(ids), credits, err)
+		t.finishRun(run, nil, err)
+		return nil, err
+	}
+
+	t.logger.Info("Refreshing coins", "cmc_ids", cmcIDs, "estimated_credits", credits)
+	report, err := t.syncIDs(ctx, ids)
+	t.budget.settle(reservation, report.CreditCount)
+	t.finishRun(run, report, err)
+	return report, err
+}
+
+// runSync registers a syncRun for ids so refreshes can join it, then syncs the coins
+func (t *TickerService) runSync(ctx context.Context, ids []string) (*SyncReport, error) {
+	run := newSyncRun(ids)
+	t.runsMu.Lock()
+	t.runs[run] = struct{}{}
+	t.runsMu.Unlock()
+
+	report, err := t.syncIDs(ctx, ids)
+	t.budget.record(report.FinishedAt, report.CreditCount)
+	t.finishRun(run, report, err)
+	return report, err
+}
+
+// newSyncRun creates a syncRun fetching ids
+func newSyncRun(ids []string) *syncRun {
+	run := &syncRun{ids: make(map[string]bool, len(ids)), done: make(chan struct{})}
+	for _, id := range ids {
+		run.ids[id] = true
+	}
+	return run
+}
+
+// claimRun returns the sync in progress covering every id (joined), or registers a new syncRun for ids. The lookup
+// and the registration hold runsMu together so concurrent refreshes of the same coins start a single run.
+// The caller of a new run must call finishRun.
+func (t *TickerService) claimRun(ids []string) (run *syncRun, joined bool) {
+	t.runsMu.Lock()
+	defer t.runsMu.Unlock()
+	if run := t.coveringRun(ids); run != nil {
+		return run, true
+	}
+	run = newSyncRun(ids)
+	t.runs[run] = struct{}{}
+	return run, false
+}
+
+// finishRun sets the result of run, unregisters it and releases the callers waiting on it
+func (t *TickerService) finishRun(run *syncRun, report *SyncReport, err error) {
+	run.report, run.err = report, err
+	t.runsMu.Lock()
+	delete(t.runs, run)
+	t.runsMu.Unlock()
+	close(run.done)
+}
+
+// coveringRun returns a sync in progress covering every id, or nil. runsMu must be held.
+func (t *TickerService) coveringRun(ids []string) *syncRun {
+	for run := range t.runs {
+		if run.covers(ids) {
+			return run
+		}
+	}
+	return nil
+}
+
+// filterReport returns a copy of report with only the outcomes of ids and counts recomputed for those outcomes
+func filterReport(report *SyncReport, ids []string) *SyncReport {
+	if report == nil {
+		return nil
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	filtered := *report
+	filtered.Outcomes = nil
+	for _, o := range report.Outcomes {
+		if wanted[strconv.Itoa(o.CmcID)] {
+			filtered.Outcomes = append(filtered.Outcomes, o)
+		}
+	}
+	filtered.NumericOverflows = nil
+	for _, o := range report.NumericOverflows {
+		if wanted[strconv.Itoa(o.CmcID)] {
+			filtered.NumericOverflows = append(filtered.NumericOverflows, o)
+		}
+	}
+	filtered.CoinsRequested = len(ids)
+	filtered.countOutcomes()
+	return &filtered
+}
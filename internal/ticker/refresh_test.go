@@ -0,0 +1,247 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// quotesHandler returns a CMC quotes handler that answers every requested ID and counts calls
+func quotesHandler(calls *atomic.Int32, delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(delay)
+		var data []string
+		for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+			data = append(data, fmt.Sprintf(`"%s":{"id":%s,"quote":{"USD":{"price":1}}}`, id, id))
+		}
+		fmt.Fprintf(w, `{"status":{"error_code":0,"credit_count":1},"data":{%s}}`, strings.Join(data, ","))
+	}
+}
+
+// TestRefresh tests that Refresh only fetches the requested coins
+func TestRefresh(t *testing.T) {
+	var calls atomic.Int32
+	var gotIDs string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIDs = r.URL.Query().Get("id")
+		quotesHandler(&calls, 0)(w, r)
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL}}
+	service := NewTickerService(cfg, &fakeCoins{ids: []int{1, 1027, 5994}}, testLogger(), server.Client())
+
+	report, err := service.Refresh(context.Background(), 1027, 1027)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if gotIDs != "1027" {
+		t.Errorf("Expected id=1027, got %s", gotIDs)
+	}
+	if report.CoinsRequested != 1 || len(report.Outcomes) != 1 {
+		t.Errorf("Expected a report for one coin, got %+v", report)
+	}
+}
+
+// TestRefreshCoalesces tests that a refresh joins a sync in progress covering its coins
+func TestRefreshCoalesces(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		quotesHandler(&calls, 100*time.Millisecond)(w, r)
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL}}
+	service := NewTickerService(cfg, &fakeCoins{ids: []int{1, 1027, 5994}}, testLogger(), server.Client())
+
+	syncDone := make(chan struct{})
+	go func() {
+		defer close(syncDone)
+		service.Sync(context.Background())
+	}()
+	// The sync registers its run before calling upstream
+	<-started
+
+	report, err := service.Refresh(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	<-syncDone
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls.Load())
+	}
+	if len(report.Outcomes) != 1 || report.Outcomes[0].CmcID != 1 {
+		t.Errorf("Expected the outcome of cmc_id 1 only, got %+v", report.Outcomes)
+	}
+}
+
+// slowProvider implements QuoteProvider with a slow credit estimate (widens the window between looking up and
+// starting a run) and fetches that block until release is closed
+type slowProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (p *slowProvider) Name() string { return "slow" }
+func (p *slowProvider) EstimateCredits(ids []string) int {
+	time.Sleep(10 * time.Millisecond)
+	return 0
+}
+func (p *slowProvider) FetchQuotes(ctx context.Context, ids []string) (*ProviderQuotes, error) {
+	p.calls.Add(1)
+	<-p.release
+	coins := make(map[string]CoinInfo, len(ids))
+	for _, id := range ids {
+		cmcID, _ := strconv.Atoi(id)
+		coins[id] = CoinInfo{CmcID: cmcID, Quote: map[string]CoinQuote{"USD": {Price: "1"}}}
+	}
+	return &ProviderQuotes{Provider: "slow", Coins: coins}, nil
+}
+
+// TestRefreshConcurrent tests that concurrent refreshes of the same coins start a single sync
+func TestRefreshConcurrent(t *testing.T) {
+	provider := &slowProvider{release: make(chan struct{})}
+	service := NewTickerService(&config.AppConfig{}, &fakeCoins{ids: []int{1, 1027}}, testLogger(), nil)
+	service.provider = provider
+
+	const refreshes = 20
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, refreshes)
+	for i := 0; i < refreshes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			report, err := service.Refresh(context.Background(), 1027, 1)
+			if err == nil && len(report.Outcomes) != 2 {
+				err = fmt.Errorf("expected 2 outcomes, got %+v", report.Outcomes)
+			}
+			errs <- err
+		}()
+	}
+	close(start)
+	// Hold the upstream call until every refresh has joined or started its run
+	time.Sleep(50 * time.Millisecond)
+	close(provider.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+}
+
+// TestRefreshCreditBudget tests that a refresh is refused when it would exceed the credit budget
+func TestRefreshCreditBudget(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(quotesHandler(&calls, 0))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL, CreditBudget: 1, CreditWindow: time.Hour}}
+	service := NewTickerService(cfg, &fakeCoins{ids: []int{1}}, testLogger(), server.Client())
+
+	// The scheduled sync spends the only credit of the budget
+	if _, err := service.Sync(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err := service.Refresh(context.Background(), 1)
+	if !errors.Is(err, ErrCreditBudgetExceeded) {
+		t.Errorf("Expected ErrCreditBudgetExceeded, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected no upstream call for the refused refresh, got %d calls", calls.Load())
+	}
+}
+
+// TestRefreshConcurrentBudget tests that concurrent refreshes of different coins cannot together exceed the budget
+func TestRefreshConcurrentBudget(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(quotesHandler(&calls, 50*time.Millisecond))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL, CreditBudget: 2, CreditWindow: time.Hour}}
+	service := NewTickerService(cfg, &fakeCoins{}, testLogger(), server.Client())
+
+	const refreshes = 10
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	var refused atomic.Int32
+	for i := 1; i <= refreshes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := service.Refresh(context.Background(), i); errors.Is(err, ErrCreditBudgetExceeded) {
+				refused.Add(1)
+			} else if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if calls.Load() != 2 || refused.Load() != refreshes-2 {
+		t.Errorf("Expected 2 upstream calls within the budget of 2 credits, got %d calls and %d refused", calls.Load(), refused.Load())
+	}
+	if used := service.budget.used(time.Now()); used != 2 {
+		t.Errorf("Expected 2 credits used, got %d", used)
+	}
+}
+
+// TestCreditBudgetReserve tests that a reservation counts against the budget until settled with the spent credits
+func TestCreditBudgetReserve(t *testing.T) {
+	budget := newCreditBudget(10, time.Hour)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	spend, err := budget.reserve(now, 8)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := budget.reserve(now, 3); !errors.Is(err, ErrCreditBudgetExceeded) {
+		t.Errorf("Expected ErrCreditBudgetExceeded, got %v", err)
+	}
+	budget.settle(spend, 2)
+	if used := budget.used(now); used != 2 {
+		t.Errorf("Expected 2 credits used after settling, got %d", used)
+	}
+	if _, err := budget.reserve(now, 8); err != nil {
+		t.Errorf("Expected the settled credits to free the budget, got %v", err)
+	}
+}
+
+// TestCreditBudgetWindow tests that credits outside the rolling window no longer count against the budget
+func TestCreditBudgetWindow(t *testing.T) {
+	budget := newCreditBudget(10, time.Hour)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	budget.record(now, 8)
+	if err := budget.allow(now, 3); !errors.Is(err, ErrCreditBudgetExceeded) {
+		t.Errorf("Expected ErrCreditBudgetExceeded, got %v", err)
+	}
+	if err := budget.allow(now.Add(2*time.Hour), 3); err != nil {
+		t.Errorf("Expected credits to expire after the window, got %v", err)
+	}
+}
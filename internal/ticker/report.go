@@ -0,0 +1,107 @@
+package ticker
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SyncReport is returned by every Sync so the scheduler, logging, metrics and admin endpoints consume the same data.
+
+// SyncReport holds the result of a single sync.
+type SyncReport struct {
+	Provider        string // name of the QuoteProvider
+	StartedAt       time.Time
+	FinishedAt      time.Time
+	Duration        time.Duration
+	CoinsRequested  int           // tracked coins requested from upstream
+	CoinsReturned   int           // coins present in the upstream response
+	CoinsWritten    int           // coins written to the database
+	CoinsSkipped    int           // coins not written because nothing changed
+	CoinsFailed     int           // coins missing upstream, invalid or failed to write
+	CreditCount     int           // upstream credit_count summed over all calls
+	UpstreamElapsed time.Duration // upstream elapsed summed over all calls
+	ChunkErrors     []ChunkError  // calls that failed, the other calls are still processed
+	Outcomes        []CoinOutcome // one outcome per requested coin
+	// Values stored as NULL (or quotes dropped for prices) because they do not fit their NUMERIC column
+	NumericOverflows []NumericOverflow
+}
+
+// ChunkError holds the error of a single upstream call (one chunk of ID's and one group of convert targets).
+type ChunkError struct {
+	IDs     []string
+	Convert string
+	Err     error
+}
+
+// Error implements the error interface
+func (c ChunkError) Error() string {
+	return fmt.Sprintf("chunk of %d ids (convert %s): %v", len(c.IDs), c.Convert, c.Err)
+}
+
+// Unwrap allows errors.Is() to match the underlying error
+func (c ChunkError) Unwrap() error {
+	return c.Err
+}
+
+// newSyncReport returns a report of provider started at now
+func newSyncReport(now time.Time, provider string) *SyncReport {
+	return &SyncReport{Provider: provider, StartedAt: now}
+}
+
+// emptyReport returns a finished report for a sync that did not call upstream
+func (t *TickerService) emptyReport() *SyncReport {
+	report := newSyncReport(t.now(), t.provider.Name())
+	report.finish(report.StartedAt)
+	return report
+}
+
+// setUpstream records the upstream response totals
+func (r *SyncReport) setUpstream(quotes *ProviderQuotes) {
+	r.CoinsReturned = len(quotes.Coins)
+	r.CreditCount = quotes.CreditCount
+	r.UpstreamElapsed = quotes.Elapsed
+}
+
+// finish sets the end time and the outcome counts
+func (r *SyncReport) finish(now time.Time) {
+	r.FinishedAt = now
+	r.Duration = r.FinishedAt.Sub(r.StartedAt)
+	r.countOutcomes()
+}
+
+// countOutcomes sets the written, skipped and failed counts from the outcomes
+func (r *SyncReport) countOutcomes() {
+	counts := CountOutcomes(r.Outcomes)
+	r.CoinsWritten = counts[OutcomeUpdated]
+	r.CoinsSkipped = counts[OutcomeUnchanged]
+	r.CoinsFailed = counts[OutcomeMissing] + counts[OutcomeInvalid] + counts[OutcomeWriteFailed]
+}
+
+// MissingCoins returns the CMC ID's of coins requested but not returned by upstream
+func (r *SyncReport) MissingCoins() []int {
+	return MissingCoins(r.Outcomes)
+}
+
+// StaleCoins returns the CMC ID's of coins flagged as stale
+func (r *SyncReport) StaleCoins() []int {
+	return StaleCoins(r.Outcomes)
+}
+
+// LogValue implements slog.LogValuer so a report can be logged as a group (logger.Info("msg", "report", report))
+func (r *SyncReport) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("provider", r.Provider),
+		slog.Time("started_at", r.StartedAt),
+		slog.Duration("duration", r.Duration),
+		slog.Int("requested", r.CoinsRequested),
+		slog.Int("returned", r.CoinsReturned),
+		slog.Int("written", r.CoinsWritten),
+		slog.Int("skipped", r.CoinsSkipped),
+		slog.Int("failed", r.CoinsFailed),
+		slog.Int("credit_count", r.CreditCount),
+		slog.Duration("upstream_elapsed", r.UpstreamElapsed),
+		slog.Int("chunk_errors", len(r.ChunkErrors)),
+		slog.Int("numeric_overflows", len(r.NumericOverflows)),
+	)
+}
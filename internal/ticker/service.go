@@ -1,47 +1,54 @@
 package ticker
 
-// Coinmarketcap (CMC) API Documentation: https://coinmarketcap.com/api/documentation/v1/
-// CMC recommends using CoinMarketCap ID's instead of ID or other identifiers
-// Common endpoints:
-// https://pro-api.coinmarketcap.com/v1/cryptocurrency/listings/latest
-// https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest
-
-// Sample CMD ID's:
-// Bitcoin CMC ID: 1
-// Ethereum CMC ID: 1027
-// Solana CMC ID: 5994
-// Sui CMC ID: 20947
-// Cardano CMC ID: 2010
-// ICP: 8916
-
 import (
 	"context"
-	"io"
+	"errors"
 	"log/slog"
 	"net/http"
-	"net/url"
-	"strings"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/jdbdev/moonramp-ticker/config"
 	"github.com/jdbdev/moonramp-ticker/internal/coins"
 )
 
-// TEMP SLICE ONLY. USE DB TABLE FOR ID MAP.
-var coinIDMap []string = []string{"1", "1027", "5994", "20947", "2010", "8916"}
-
-// TickerInterface has a singular method for TickerService to orchestrate the sync process from API to DB.
+// TickerInterface has the methods for TickerService to orchestrate the sync process from API to DB.
+// Sync gets quotes from the configured QuoteProvider (see provider.go) and always returns a SyncReport (including on error) with one CoinOutcome per tracked coin.
+// SyncTier syncs only the coins of one polling tier (see tier.go).
+// Refresh syncs only the given coins right away (see refresh.go).
+// RebuildCandles recomputes OHLC candles for a time range from stored quote history.
+// Backfill fills quote history for one coin from the historical endpoint (see backfill.go).
+// RepairGaps finds and backfills gaps in the recent quote history of tracked coins (see gaps.go).
+// SyncGlobalMetrics appends the global market metrics to their time series (see globalmetrics.go).
 type TickerInterface interface {
-	Sync(ctx context.Context) error
+	Sync(ctx context.Context) (*SyncReport, error)
+	SyncTier(ctx context.Context, tier string) (*SyncReport, error)
+	Refresh(ctx context.Context, cmcIDs ...int) (*SyncReport, error)
+	RebuildCandles(ctx context.Context, from, to time.Time) error
+	Backfill(ctx context.Context, req BackfillRequest) (*BackfillReport, error)
+	RepairGaps(ctx context.Context) (*GapReport, error)
+	SyncGlobalMetrics(ctx context.Context) (*GlobalMetrics, error)
 }
 
 // TickerService implements the TickerInterface that can sync data from API to DB.
 type TickerService struct {
-	apiKey    string
-	baseURL   string
-	quotesURL string
-	client    *http.Client
-	logger    *slog.Logger
-	coins     coins.CoinInterface
+	provider    QuoteProvider  // upstream quotes used by Sync
+	cmc         *CMCProvider   // CMC client and convert targets used by backfill and global metrics
+	globalURL   string         // global metrics endpoint
+	mode        string         // SyncModeQuotes or SyncModeListings
+	backfill    backfillParams // historical backfill settings
+	gaps        gapParams      // history gap repair settings
+	staleAfter  time.Duration  // flag coins whose upstream last_updated is older than this
+	now         func() time.Time
+	rules       config.ValidationSettings // quote sanity checks applied before UpdateDB
+	budget      *creditBudget             // credits spent within the budget window
+	tiers       map[string]bool           // configured polling tiers
+	defaultTier string                    // tier of coins with an unknown tier
+	runsMu      sync.Mutex
+	runs        map[*syncRun]struct{} // syncs in progress, joined by Refresh
+	logger      *slog.Logger
+	coins       coins.CoinInterface
 	// data    []TickerData // Add a field to store the decoded data
 }
 
@@ -58,121 +65,237 @@ func NewTickerService(app *config.AppConfig, coinService coins.CoinInterface, lo
 	if app.CMC.APIKey == "" {
 		logger.Warn("No API key provided - requires API key")
 	}
-	if app.CMC.QuotesURL == "" {
-		logger.Warn("No quotes URL provided - requires quotes URL")
+	provider := newQuoteProvider(app, client, logger)
+	// Backfill and global metrics always use CMC, share the provider when CMC is also the quote provider
+	cmc, ok := provider.(*CMCProvider)
+	if !ok {
+		cmc = NewCMCProvider(app.CMC, client, logger.With("provider", ProviderCMC))
+	}
+	mode := app.CMC.SyncMode
+	if mode != SyncModeQuotes && mode != SyncModeListings {
+		logger.Warn("Unknown sync mode - using quotes mode", "sync_mode", mode)
+		mode = SyncModeQuotes
+	}
+	if _, ok := provider.(ListingsProvider); mode == SyncModeListings && !ok {
+		logger.Warn("Provider does not support listings mode - using quotes mode", "provider", provider.Name())
+		mode = SyncModeQuotes
+	}
+	if mode == SyncModeListings && app.CMC.ListingsURL == "" {
+		logger.Warn("No listings URL provided - requires listings URL in listings mode")
 	}
 	if client == nil {
 		logger.Warn("No HTTP client provided - requires HTTP client")
 	}
-	logger.Info("TickerService initialized successfully")
+	if coinService == nil {
+		logger.Warn("No coin service provided - requires coin service for tracked coins")
+	}
+	tiers, defaultTier := buildTiers(app.Interval.Tiers, app.Interval.DefaultTier)
+	if defaultTier != app.Interval.DefaultTier {
+		logger.Warn("Default tier not configured - using first tier", "default_tier", app.Interval.DefaultTier, "tier", defaultTier)
+	}
+	logger.Info("TickerService initialized successfully", "provider", provider.Name())
 
 	// Return struct with values
 	return &TickerService{
-		apiKey:    app.CMC.APIKey,
-		baseURL:   app.CMC.BaseURL,
-		quotesURL: app.CMC.QuotesURL,
-		client:    client,
-		logger:    logger,
-		coins:     coinService,
+		provider:  provider,
+		cmc:       cmc,
+		globalURL: app.CMC.GlobalURL,
+		mode:      mode,
+		backfill: backfillParams{
+			url:       app.CMC.HistoricalURL,
+			creditCap: app.CMC.BackfillCreditCap,
+			pageSize:  backfillPageSize(app.CMC.BackfillPageSize),
+		},
+		gaps:        buildGapParams(app.Interval),
+		staleAfter:  app.Interval.StaleAfter,
+		now:         time.Now,
+		rules:       app.Validate,
+		budget:      newCreditBudget(app.CMC.CreditBudget, app.CMC.CreditWindow),
+		tiers:       tiers,
+		defaultTier: defaultTier,
+		runs:        make(map[*syncRun]struct{}),
+		logger:      logger,
+		coins:       coinService,
 	}
 }
 
-// Sync fetches data from CMC API, decodes to JSON and updates the database
-func (t *TickerService) Sync(ctx context.Context) error {
-	// Call and return data from CMC API as []byte
-	data, err := t.CallAPI(ctx)
+// Sync fetches quotes from the provider for the enabled tracked coins and updates the database.
+// Tracked coins are read on every sync so adding a coin takes effect without a redeploy.
+// In listings mode the top coins from the listings endpoint are synced instead (see listings.go).
+// Returns a SyncReport with one CoinOutcome per tracked coin. Missing and invalid coins and failed chunks do not
+// stop healthy coins from being written.
+func (t *TickerService) Sync(ctx context.Context) (*SyncReport, error) {
+	if t.mode == SyncModeListings {
+		return t.syncListings(ctx)
+	}
+
+	ids, err := t.trackedIDs(ctx)
 	if err != nil {
-		t.logger.Error("failed to fetch and decode data", "error", err)
-		return err
-	}
-	// Decode []byte data into CMCResponse struct
-	myStruct, err := t.DecodeData(data)
-	// Update the database with the new data from CMCResponse struct
-	t.UpdateDB(myStruct)
-	return nil
+		t.logger.Error("failed to get tracked coins", "error", err)
+		return t.emptyReport(), err
+	}
+	if len(ids) == 0 {
+		t.logger.Info("No tracked coins - skipping upstream call")
+	}
+	return t.runSync(ctx, ids)
 }
 
-// CallAPI gets and decodes data from CMC and returns a []byte of the JSON response
-func (t *TickerService) CallAPI(ctx context.Context) ([]byte, error) {
+// syncIDs fetches, validates and writes quotes for ids and returns the SyncReport.
+// The caller records the credits reported by upstream against the credit budget.
+func (t *TickerService) syncIDs(ctx context.Context, ids []string) (*SyncReport, error) {
+	report := newSyncReport(t.now(), t.provider.Name())
+	defer func() { report.finish(t.now()) }()
+
+	report.CoinsRequested = len(ids)
+	if len(ids) == 0 {
+		return report, nil
+	}
 
-	// Create new request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", t.quotesURL, nil)
+	// Fetch quotes from the provider. Stop before UpdateDB if every call failed.
+	quotes, err := t.provider.FetchQuotes(ctx, ids)
+	if quotes != nil {
+		report.ChunkErrors = quotes.ChunkErrors
+	}
 	if err != nil {
-		t.logger.Error("failed to create request", "error", err)
-		return nil, err
+		return report, err
 	}
+	report.setUpstream(quotes)
+	return report, t.persist(ctx, report, ids, quotes)
+}
 
-	// Build query parameters
-	q := url.Values{}
+// persist validates and writes the coins of a provider fetch and sets the report outcomes. ids are the requested
+// coins, coins missing from the response are reported as missing (with their chunk error if the call failed).
+func (t *TickerService) persist(ctx context.Context, report *SyncReport, ids []string, quotes *ProviderQuotes) error {
+	// Report missing and invalid coins
+	valid, outcomes := splitResponse(ids, quotes)
 
-	// Collect all IDs from the map
-	q.Add("id", strings.Join(coinIDMap, ",")) // Join IDs with commas and add to query
-	q.Add("convert", "USD")
+	// Validate quotes against the last stored quotes and quarantine rejected quotes
+	stored := t.storedQuotes(ctx, valid)
+	valid, rejected, invalid := validateQuotes(valid, stored, t.rules)
+	outcomes = append(outcomes, invalid...)
+	t.quarantine(ctx, rejected)
 
-	// Only get requested fields (automatically get price, market_cap, volume_24h, etc. in "quotes"):
-	// Available aux fields: num_market_pairs, cmc_rank, date_added, tags, platform, max_supply,
-	// circulating_supply, total_supply, market_cap_by_total_supply, volume_24h_reported,
-	// volume_7d, volume_7d_reported, volume_30d, volume_30d_reported, is_active, is_fiat
-	q.Add("aux", "circulating_supply,total_supply,volume_24h_reported")
+	// Store values that do not fit their NUMERIC column as NULL and report them
+	valid, overflows, invalid := fitNumerics(valid)
+	outcomes = append(outcomes, invalid...)
+	report.NumericOverflows = overflows
+	for _, o := range overflows {
+		t.logger.Warn("value does not fit numeric column", "cmc_id", o.CmcID, "symbol", o.Symbol,
+			"currency", o.Currency, "field", o.Field, "value", o.Value)
+	}
 
-	// Set headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-CMC_PRO_API_KEY", t.apiKey)
+	// Skip quotes whose upstream last_updated has not changed since the last write
+	changed, unchanged := filterUnchanged(valid, stored)
+	outcomes = append(outcomes, unchanged...)
 
-	// Add query parameters to URL
-	req.URL.RawQuery = q.Encode()
+	// Update the database with the remaining coins
+	written, err := t.UpdateDB(ctx, &ProviderQuotes{
+		Provider: quotes.Provider, Coins: changed, QuotesOnly: quotes.QuotesOnly, MarketFields: quotes.MarketFields,
+	})
+	outcomes = append(outcomes, written...)
 
-	// Execute request
-	resp, err := t.client.Do(req)
+	now := t.now()
+	for i, o := range outcomes {
+		if coin, ok := valid[strconv.Itoa(o.CmcID)]; ok && isStale(coin, now, t.staleAfter) {
+			outcomes[i].Stale = true
+			t.logger.Warn("coin quote is stale", "cmc_id", o.CmcID, "symbol", o.Symbol, "stale_after", t.staleAfter)
+		}
+		if o.Err != nil {
+			t.logger.Warn("coin not updated", "cmc_id", o.CmcID, "symbol", o.Symbol, "status", o.Status, "error", o.Err)
+		}
+	}
+	report.Outcomes = outcomes
+	return err
+}
+
+// trackedIDs returns the CMC ID's of the enabled tracked coins as query parameter values
+func (t *TickerService) trackedIDs(ctx context.Context) ([]string, error) {
+	if t.coins == nil {
+		return nil, errors.New("coin service not provided")
+	}
+	cmcIDs, err := t.coins.GetTrackedCMCIDs(ctx)
 	if err != nil {
-		t.logger.Error("HTTP request failed", "error", err, "url", req.URL.String())
 		return nil, err
-	} else {
-		t.logger.Info("HTTP request successful", "status", resp.Status, "url", req.URL.String())
 	}
-	defer resp.Body.Close()
+	ids := make([]string, 0, len(cmcIDs))
+	for _, id := range cmcIDs {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return ids, nil
+}
 
-	// Read and debug response body
-	respBody, err := io.ReadAll(resp.Body)
+// UpdateDB upserts coin_info and coin_quote rows from the coins of a provider fetch in a single transaction.
+// Returns one CoinOutcome per coin: updated, unchanged if it has no coin_info row to write to (ErrNoCoinInfo), or
+// write_failed if its rows were rolled back.
+// Skips the update if the database is not connected (USE_DB=false) and reports every coin as unchanged.
+func (t *TickerService) UpdateDB(ctx context.Context, quotes *ProviderQuotes) ([]CoinOutcome, error) {
+	if quotes == nil || len(quotes.Coins) == 0 {
+		t.logger.Info("No coin data to update")
+		return nil, nil
+	}
+	data := quotes.Coins
+	sqlDB := t.database()
+	if sqlDB == nil {
+		t.logger.Warn("Database not connected - skipping update", "coins_count", len(data))
+		return coinOutcomes(data, OutcomeUnchanged, nil), nil
+	}
+
+	failed, err := writeQuotes(ctx, sqlDB, data, quotes.coinInfoMode())
 	if err != nil {
-		t.logger.Error("failed to read response body", "error", err)
-		return nil, err
+		t.logger.Error("failed to update database, sync rolled back", "error", err)
+		return coinOutcomes(data, OutcomeWriteFailed, err), err
+	}
+
+	outcomes := make([]CoinOutcome, 0, len(data))
+	for _, k := range sortedKeys(data) {
+		coin := data[k]
+		outcome := CoinOutcome{CmcID: coin.CmcID, Symbol: coin.Symbol, Status: OutcomeUpdated}
+		if coinErr, ok := failed[k]; ok {
+			outcome.Status, outcome.Err = OutcomeWriteFailed, coinErr
+			if errors.Is(coinErr, ErrNoCoinInfo) {
+				outcome.Status = OutcomeUnchanged
+			}
+		}
+		outcomes = append(outcomes, outcome)
 	}
+	t.logger.Info("Database updated", "coins_count", len(data)-len(failed), "failed_count", len(failed))
+	return outcomes, nil
+}
 
-	return respBody, nil
-
-	// // Unmarshal JSON response into CMCResponse struct
-	// var cmcResponse CMCResponse
-	// if err := json.Unmarshal(respBody, &cmcResponse); err != nil {
-	// 	t.logger.Error("failed to unmarshal response", "error", err)
-	// 	return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	// }
-
-	// // Check for API errors
-	// if cmcResponse.Status.ErrorCode != 0 {
-	// 	errorMsg := "API error"
-	// 	if cmcResponse.Status.ErrorMessage != nil {
-	// 		errorMsg = *cmcResponse.Status.ErrorMessage
-	// 	}
-	// 	t.logger.Error("Coinmarketcap API returned error",
-	// 		"error_code", cmcResponse.Status.ErrorCode,
-	// 		"error_message", errorMsg,
-	// 		"credit_count", cmcResponse.Status.CreditCount)
-	// 	return nil, fmt.Errorf("API error (code %d): %s", cmcResponse.Status.ErrorCode, errorMsg)
-	// }
-
-	// t.logger.Info("Successfully fetched and decoded CMC data",
-	// 	"coins_count", len(cmcResponse.Data),
-	// 	"credit_count", cmcResponse.Status.CreditCount)
-	// return &cmcResponse, nil
+// storedQuotes returns the stored coin_quote rows for the coins in data, or nil if the database is not connected
+// or the lookup fails (validation against previous prices and unchanged checks are then skipped).
+func (t *TickerService) storedQuotes(ctx context.Context, data map[string]CoinInfo) map[quoteKey]storedQuote {
+	sqlDB := t.database()
+	if sqlDB == nil || len(data) == 0 {
+		return nil
+	}
+	stored, err := loadStoredQuotes(ctx, sqlDB, data)
+	if err != nil {
+		t.logger.Warn("failed to load stored quotes", "error", err)
+		return nil
+	}
+	return stored
 }
 
-// DecodeData decodes a JSON[]byte into a CMCResponse struct
-func (t *TickerService) DecodeData(data []byte) (*CMCResponse, error) {
-	return nil, nil
+// quarantine logs rejected quotes and writes them to coin_quote_quarantine if the database is connected
+func (t *TickerService) quarantine(ctx context.Context, rejected []QuoteRejection) {
+	for _, r := range rejected {
+		t.logger.Warn("quote rejected by validation", "cmc_id", r.CmcID, "symbol", r.Symbol, "currency", r.Currency, "reason", r.Err)
+	}
+	sqlDB := t.database()
+	if sqlDB == nil || len(rejected) == 0 {
+		return
+	}
+	if err := quarantineQuotes(ctx, sqlDB, rejected); err != nil {
+		t.logger.Error("failed to quarantine rejected quotes", "error", err)
+	}
 }
 
-// UpdateDB updates the database with data from CMCResponse struct
-func (t *TickerService) UpdateDB(myStruct *CMCResponse) error {
-	return nil
+// coinOutcomes returns the same outcome for every coin in data
+func coinOutcomes(data map[string]CoinInfo, status OutcomeStatus, err error) []CoinOutcome {
+	outcomes := make([]CoinOutcome, 0, len(data))
+	for _, k := range sortedKeys(data) {
+		outcomes = append(outcomes, CoinOutcome{CmcID: data[k].CmcID, Symbol: data[k].Symbol, Status: status, Err: err})
+	}
+	return outcomes
 }
@@ -1,179 +1,291 @@
 package ticker
 
-// import (
-// 	"net/http"
-// 	"net/http/httptest"
-// 	"testing"
-
-// 	"github.com/jdbdev/go-cmc/config"
-// )
-
-// // TestNewTickerService tests the creation of a new ticker service
-// func TestNewTickerService(t *testing.T) {
-// 	// 1. Setup test data
-// 	cfg := &config.AppConfig{
-// 		CMC: config.CMCSettings{
-// 			APIKey:    "test-key",             // Test value
-// 			QuotesURL: "https://test-url.com", // Test value
-// 		},
-// 	}
-
-// 	// 2. Execute the code we want to test
-// 	service := NewTickerService(cfg)
-
-// 	// 3. Assert our expectations
-// 	if service.apiKey != "test-key" {
-// 		// t.Errorf reports a test failure with a formatted message
-// 		t.Errorf("Expected apiKey to be 'test-key', got %s", service.apiKey)
-// 	}
-// }
-
-// // Version 2: Using subtests for better organization
-// func TestNewTickerService_WithSubtests(t *testing.T) {
-// 	// Setup once for all subtests
-// 	cfg := &config.AppConfig{
-// 		CMC: config.CMCSettings{
-// 			APIKey:    "test-key",
-// 			QuotesURL: "https://test-url.com",
-// 		},
-// 	}
-// 	service := NewTickerService(cfg)
-
-// 	// Subtest for API Key
-// 	t.Run("API Key", func(t *testing.T) {
-// 		if service.apiKey != "test-key" {
-// 			t.Errorf("Expected apiKey to be 'test-key', got %s", service.apiKey)
-// 		}
-// 	})
-
-// 	// Subtest for Quotes URL
-// 	t.Run("Quotes URL", func(t *testing.T) {
-// 		if service.quotesURL != "https://test-url.com" {
-// 			t.Errorf("Expected quotesURL to be 'https://test-url.com', got %s", service.quotesURL)
-// 		}
-// 	})
-// }
-
-// // Version 3: Using table tests for multiple cases
-// func TestNewTickerService_TableDriven(t *testing.T) {
-// 	tests := []struct {
-// 		name    string
-// 		config  *config.AppConfig
-// 		wantKey string
-// 		wantURL string
-// 		wantErr bool
-// 	}{
-// 		{
-// 			name: "valid config",
-// 			config: &config.AppConfig{
-// 				CMC: config.CMCSettings{
-// 					APIKey:    "test-key",
-// 					QuotesURL: "https://test-url.com",
-// 				},
-// 			},
-// 			wantKey: "test-key",
-// 			wantURL: "https://test-url.com",
-// 			wantErr: false,
-// 		},
-// 		{
-// 			name: "empty config",
-// 			config: &config.AppConfig{
-// 				CMC: config.CMCSettings{},
-// 			},
-// 			wantKey: "",
-// 			wantURL: "",
-// 			wantErr: false,
-// 		},
-// 	}
-
-// 	for _, tt := range tests {
-// 		t.Run(tt.name, func(t *testing.T) {
-// 			service := NewTickerService(tt.config)
-
-// 			if service.apiKey != tt.wantKey {
-// 				t.Errorf("apiKey = %v, want %v", service.apiKey, tt.wantKey)
-// 			}
-
-// 			if service.quotesURL != tt.wantURL {
-// 				t.Errorf("quotesURL = %v, want %v", service.quotesURL, tt.wantURL)
-// 			}
-// 		})
-// 	}
-// }
-
-// // TestFetchAndDecodeData tests the API call functionality
-// func TestFetchAndDecodeData(t *testing.T) {
-// 	// Create a mock server
-// 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 		// Test request headers
-// 		if r.Header.Get("X-CMC_PRO_API_KEY") != "test-key" {
-// 			t.Errorf("Expected API key header 'test-key', got %s", r.Header.Get("X-CMC_PRO_API_KEY"))
-// 		}
-
-// 		// Test query parameters
-// 		query := r.URL.Query()
-// 		if !query.Has("convert") {
-// 			t.Error("Missing 'convert' parameter")
-// 		}
-// 		if query.Get("convert") != "USD" {
-// 			t.Errorf("Expected convert=USD, got %s", query.Get("convert"))
-// 		}
-
-// 		// Return a mock response
-// 		w.Header().Set("Content-Type", "application/json")
-// 		w.WriteHeader(http.StatusOK)
-// 		w.Write([]byte(`{
-// 			"status": {
-// 				"timestamp": "2024-01-01T00:00:00.000Z",
-// 				"error_code": 0,
-// 				"error_message": null
-// 			},
-// 			"data": {
-// 				"1": {
-// 					"symbol": "BTC",
-// 					"quote": {
-// 						"USD": {
-// 							"price": 50000.00
-// 						}
-// 					}
-// 				}
-// 			}
-// 		}`))
-// 	}))
-// 	defer server.Close()
-
-// 	// Create service with mock server URL
-// 	cfg := &config.AppConfig{
-// 		CMC: config.CMCSettings{
-// 			APIKey:    "test-key",
-// 			QuotesURL: server.URL,
-// 		},
-// 	}
-// 	service := NewTickerService(cfg)
-
-// 	// Test the API call
-// 	err := service.FetchAndDecodeData()
-// 	if err != nil {
-// 		t.Errorf("Expected no error, got %v", err)
-// 	}
-// }
-
-// // Example of table-driven test
-// func TestCMCIDMap(t *testing.T) {
-// 	tests := []struct {
-// 		symbol string
-// 		id     string
-// 	}{
-// 		{"BTC", "1"},
-// 		{"ETH", "1027"},
-// 		{"SOL", "5994"},
-// 	}
-
-// 	for _, tt := range tests {
-// 		t.Run(tt.symbol, func(t *testing.T) {
-// 			if id := CMCIDMap[tt.symbol]; id != tt.id {
-// 				t.Errorf("Expected %s ID to be %s, got %s", tt.symbol, tt.id, id)
-// 			}
-// 		})
-// 	}
-// }
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+	"github.com/jdbdev/moonramp-ticker/internal/coins"
+)
+
+// testLogger discards log output during tests
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeCoins implements coins.CoinInterface with a fixed list of tracked CMC ID's and their tiers
+type fakeCoins struct {
+	ids   []int
+	tiers map[int]string // cmc_id -> tier, empty tier if not set
+}
+
+func (f *fakeCoins) InitializeCoinTable() error         { return nil }
+func (f *fakeCoins) AddTrackedCoin(symbol string) error { return nil }
+func (f *fakeCoins) GetTrackedCMCIDs(ctx context.Context) ([]int, error) {
+	return f.ids, nil
+}
+func (f *fakeCoins) GetTrackedCoins(ctx context.Context) ([]coins.TrackedCoin, error) {
+	var tracked []coins.TrackedCoin
+	for _, id := range f.ids {
+		tracked = append(tracked, coins.TrackedCoin{CmCID: id, Enableed: true, Tier: f.tiers[id]})
+	}
+	return tracked, nil
+}
+
+// TestNewTickerService tests the creation of a new ticker service
+func TestNewTickerService(t *testing.T) {
+	cfg := &config.AppConfig{
+		CMC: config.CMCSettings{
+			APIKey:    "test-key",
+			QuotesURL: "https://test-url.com",
+		},
+	}
+	service := NewTickerService(cfg, nil, testLogger(), http.DefaultClient)
+
+	t.Run("API Key", func(t *testing.T) {
+		if key := service.cmc.rest.header.Get("X-CMC_PRO_API_KEY"); key != "test-key" {
+			t.Errorf("Expected apiKey to be 'test-key', got %s", key)
+		}
+		if service.cmc != service.provider {
+			t.Error("Expected backfill and global metrics to share the CMC provider")
+		}
+	})
+
+	t.Run("Quotes URL", func(t *testing.T) {
+		provider, ok := service.provider.(*CMCProvider)
+		if !ok {
+			t.Fatalf("Expected CMC provider by default, got %T", service.provider)
+		}
+		if provider.quotesURL != "https://test-url.com" {
+			t.Errorf("Expected quotesURL to be 'https://test-url.com', got %s", provider.quotesURL)
+		}
+	})
+
+	t.Run("CMC settings with another provider", func(t *testing.T) {
+		cfg := &config.AppConfig{
+			Provider: config.ProviderSettings{Name: ProviderCoinGecko},
+			CMC:      config.CMCSettings{APIKey: "test-key", Convert: []string{"USD", "EUR"}, MaxConvertPerCall: 2},
+		}
+		service := NewTickerService(cfg, nil, testLogger(), http.DefaultClient)
+		if key := service.cmc.rest.header.Get("X-CMC_PRO_API_KEY"); key != "test-key" {
+			t.Errorf("Expected apiKey to be 'test-key', got %s", key)
+		}
+		if len(service.cmc.convert) != 1 || service.cmc.convert[0].String() != "USD,EUR" {
+			t.Errorf("Expected the configured convert targets, got %+v", service.cmc.convert)
+		}
+	})
+}
+
+// TestDecodeData tests decoding of CMC responses and mapping of API error codes to typed errors
+func TestDecodeData(t *testing.T) {
+	provider := NewCMCProvider(config.CMCSettings{}, http.DefaultClient, testLogger())
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{
+			name: "valid response",
+			body: `{"status":{"timestamp":"2024-01-01T00:00:00.000Z","error_code":0,"error_message":null,"credit_count":1},
+				"data":{"1":{"id":1,"symbol":"BTC","quote":{"USD":{"price":50000.00}}}}}`,
+			wantErr: nil,
+		},
+		{
+			name:    "invalid api key",
+			body:    `{"status":{"error_code":1001,"error_message":"This API Key is invalid."}}`,
+			wantErr: ErrUnauthorized,
+		},
+		{
+			name:    "minute rate limit",
+			body:    `{"status":{"error_code":1008,"error_message":"You've exceeded your API Key's HTTP request rate limit."}}`,
+			wantErr: ErrRateLimited,
+		},
+		{
+			name:    "plan not authorized",
+			body:    `{"status":{"error_code":1006,"error_message":"Your API Key subscription plan doesn't support this endpoint."}}`,
+			wantErr: ErrPlanLimit,
+		},
+		{
+			name:    "invalid id",
+			body:    `{"status":{"error_code":400,"error_message":"Invalid value for \"id\": \"999999999\""}}`,
+			wantErr: ErrInvalidID,
+		},
+		{
+			name:    "unknown error code",
+			body:    `{"status":{"error_code":500,"error_message":"An internal server error occurred"}}`,
+			wantErr: ErrAPI,
+		},
+		{
+			name:    "malformed json",
+			body:    `{"status":`,
+			wantErr: ErrDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := provider.DecodeData([]byte(tt.body))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if resp.Data["1"].Quote["USD"].Price.Cmp("50000") != 0 {
+					t.Errorf("Expected BTC price 50000, got %v", resp.Data["1"].Quote["USD"].Price)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if resp != nil {
+				t.Errorf("Expected nil response on error, got %+v", resp)
+			}
+		})
+	}
+
+	t.Run("APIError code and message", func(t *testing.T) {
+		_, err := provider.DecodeData([]byte(`{"status":{"error_code":1009,"error_message":"daily limit"}}`))
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected *APIError, got %T", err)
+		}
+		if apiErr.Code != 1009 || apiErr.Message != "daily limit" {
+			t.Errorf("Expected code 1009 and message 'daily limit', got %d %q", apiErr.Code, apiErr.Message)
+		}
+	})
+}
+
+// TestCMCError tests that CMC error responses are mapped by the status error code and by HTTP status otherwise
+func TestCMCError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+		wantMsg string
+	}{
+		{"status error code", http.StatusTooManyRequests, `{"status":{"error_code":1008,"error_message":"minute limit"}}`, ErrRateLimited, "minute limit"},
+		{"no status", http.StatusForbidden, `{"status":{"error_code":0}}`, ErrPlanLimit, `{"status":{"error_code":0}}`},
+		{"not JSON", http.StatusBadGateway, strings.Repeat("x", 300), ErrAPI, strings.Repeat("x", maxErrorBody)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmcError(tt.status, []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if err.Message != tt.wantMsg {
+				t.Errorf("Expected message %q, got %q", tt.wantMsg, err.Message)
+			}
+		})
+	}
+}
+
+// TestSyncStopsOnAPIError tests that Sync returns the typed error from DecodeData
+func TestSyncStopsOnAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CMC_PRO_API_KEY") != "test-key" {
+			t.Errorf("Expected API key header 'test-key', got %s", r.Header.Get("X-CMC_PRO_API_KEY"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":{"error_code":1002,"error_message":"API key missing."}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{
+		CMC: config.CMCSettings{
+			APIKey:    "test-key",
+			QuotesURL: server.URL,
+		},
+	}
+	service := NewTickerService(cfg, &fakeCoins{ids: []int{1, 1027}}, testLogger(), server.Client())
+
+	_, err := service.Sync(context.Background())
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+}
+
+// TestSyncTrackedCoins tests that Sync builds the query from tracked coins and skips the call when there are none
+func TestSyncTrackedCoins(t *testing.T) {
+	var gotIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIDs = append(gotIDs, r.URL.Query().Get("id"))
+		w.Write([]byte(`{"status":{"error_code":0},"data":{}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL}}
+	coinService := &fakeCoins{}
+	service := NewTickerService(cfg, coinService, testLogger(), server.Client())
+
+	t.Run("no tracked coins", func(t *testing.T) {
+		if _, err := service.Sync(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(gotIDs) != 0 {
+			t.Errorf("Expected no upstream call, got %v", gotIDs)
+		}
+	})
+
+	t.Run("tracked coin added", func(t *testing.T) {
+		coinService.ids = []int{1, 5994}
+		if _, err := service.Sync(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(gotIDs) != 1 || gotIDs[0] != "1,5994" {
+			t.Errorf("Expected one call with id=1,5994, got %v", gotIDs)
+		}
+	})
+}
+
+// TestSyncOutcomes tests that missing and invalid coins are reported while healthy coins are still processed
+func TestSyncOutcomes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 1 is healthy, 1027 has no quotes and 999999 is skipped by CMC (skip_invalid=true)
+		w.Write([]byte(`{"status":{"error_code":0},"data":{
+			"1":{"id":1,"symbol":"BTC","quote":{"USD":{"price":50000}}},
+			"1027":{"id":1027,"symbol":"ETH","quote":{}}}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{CMC: config.CMCSettings{QuotesURL: server.URL}}
+	service := NewTickerService(cfg, &fakeCoins{ids: []int{1, 1027, 999999}}, testLogger(), server.Client())
+
+	report, err := service.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got := make(map[int]OutcomeStatus)
+	for _, o := range report.Outcomes {
+		got[o.CmcID] = o.Status
+	}
+	want := map[int]OutcomeStatus{
+		1:      OutcomeUnchanged, // database not connected in tests
+		1027:   OutcomeInvalid,
+		999999: OutcomeMissing,
+	}
+	for id, status := range want {
+		if got[id] != status {
+			t.Errorf("cmc_id %d: expected %s, got %s", id, status, got[id])
+		}
+	}
+	if missing := report.MissingCoins(); len(missing) != 1 || missing[0] != 999999 {
+		t.Errorf("Expected missing coins [999999], got %v", missing)
+	}
+	if report.CoinsRequested != 3 || report.CoinsReturned != 2 || report.CoinsSkipped != 1 || report.CoinsFailed != 2 {
+		t.Errorf("Unexpected report counts: %+v", report)
+	}
+	if report.FinishedAt.Before(report.StartedAt) {
+		t.Errorf("Expected FinishedAt after StartedAt, got %v - %v", report.StartedAt, report.FinishedAt)
+	}
+}
@@ -0,0 +1,236 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jdbdev/moonramp-ticker/db"
+	"github.com/lib/pq"
+)
+
+// SQL statements for the coin_info, coin_quote and coin_quote_history tables (see migrations/ticker).
+// Upserts are keyed on coin_info.cmc_id and the coin_quote unique_coin_quote (coin_id, currency) constraint so re-running
+// a sync is idempotent. History rows are append only and keyed on (coin_id, currency, last_updated) so re-syncing the same upstream timestamp is a no-op.
+const (
+	upsertCoinInfoSQL = `
+INSERT INTO coin_info (cmc_id, name, symbol, slug, cmc_rank, num_market_pairs, tags, max_supply,
+    circulating_supply, total_supply, infinite_supply, self_reported_circulating_supply,
+    self_reported_market_cap, tvl_ratio, last_updated)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+ON CONFLICT (cmc_id) DO UPDATE SET
+    name = EXCLUDED.name,
+    symbol = EXCLUDED.symbol,
+    slug = EXCLUDED.slug,
+    cmc_rank = EXCLUDED.cmc_rank,
+    num_market_pairs = EXCLUDED.num_market_pairs,
+    tags = EXCLUDED.tags,
+    max_supply = EXCLUDED.max_supply,
+    circulating_supply = EXCLUDED.circulating_supply,
+    total_supply = EXCLUDED.total_supply,
+    infinite_supply = EXCLUDED.infinite_supply,
+    self_reported_circulating_supply = EXCLUDED.self_reported_circulating_supply,
+    self_reported_market_cap = EXCLUDED.self_reported_market_cap,
+    tvl_ratio = EXCLUDED.tvl_ratio,
+    last_updated = EXCLUDED.last_updated,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING id`
+
+	// selectCoinInfoIDSQL returns the coin_info id of a coin, no row if the coin has never been written
+	selectCoinInfoIDSQL = `SELECT id FROM coin_info WHERE cmc_id = $1`
+
+	// upsertMarketCoinInfoSQL upserts the coin_info columns filled by market data providers (see
+	// ProviderQuotes.MarketFields). CMC only columns (slug, cmc_rank, tags, ...) keep their stored values.
+	upsertMarketCoinInfoSQL = `
+INSERT INTO coin_info (cmc_id, name, symbol, slug, max_supply, circulating_supply, total_supply, last_updated)
+VALUES ($1, $2, $3, '', $4, $5, $6, $7)
+ON CONFLICT (cmc_id) DO UPDATE SET
+    name = EXCLUDED.name,
+    symbol = EXCLUDED.symbol,
+    max_supply = EXCLUDED.max_supply,
+    circulating_supply = EXCLUDED.circulating_supply,
+    total_supply = EXCLUDED.total_supply,
+    last_updated = EXCLUDED.last_updated,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING id`
+
+	upsertCoinQuoteSQL = `
+INSERT INTO coin_quote (coin_id, currency, price, market_cap, fully_diluted_market_cap, volume_24h,
+    volume_24h_reported, volume_change_24h, percent_change_1h, percent_change_24h, percent_change_7d,
+    percent_change_30d, percent_change_60d, percent_change_90d, tvl, last_updated)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+ON CONFLICT ON CONSTRAINT unique_coin_quote DO UPDATE SET
+    price = EXCLUDED.price,
+    market_cap = EXCLUDED.market_cap,
+    fully_diluted_market_cap = EXCLUDED.fully_diluted_market_cap,
+    volume_24h = EXCLUDED.volume_24h,
+    volume_24h_reported = EXCLUDED.volume_24h_reported,
+    volume_change_24h = EXCLUDED.volume_change_24h,
+    percent_change_1h = EXCLUDED.percent_change_1h,
+    percent_change_24h = EXCLUDED.percent_change_24h,
+    percent_change_7d = EXCLUDED.percent_change_7d,
+    percent_change_30d = EXCLUDED.percent_change_30d,
+    percent_change_60d = EXCLUDED.percent_change_60d,
+    percent_change_90d = EXCLUDED.percent_change_90d,
+    tvl = EXCLUDED.tvl,
+    last_updated = EXCLUDED.last_updated,
+    updated_at = CURRENT_TIMESTAMP`
+
+	insertCoinQuoteHistorySQL = `
+INSERT INTO coin_quote_history (coin_id, currency, price, market_cap, fully_diluted_market_cap, volume_24h,
+    volume_24h_reported, volume_change_24h, percent_change_1h, percent_change_24h, percent_change_7d,
+    percent_change_30d, percent_change_60d, percent_change_90d, tvl, last_updated)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+ON CONFLICT ON CONSTRAINT unique_coin_quote_history DO NOTHING`
+)
+
+// coinInfoMode selects the coin_info columns written with the quotes of a provider
+type coinInfoMode int
+
+const (
+	coinInfoFull   coinInfoMode = iota // every column (CMC)
+	coinInfoMarket                     // name, symbol, supplies and last_updated (ProviderQuotes.MarketFields)
+	coinInfoEnsure                     // row created if missing, never updated (ProviderQuotes.QuotesOnly)
+)
+
+// coinInfoMode returns the coin_info columns the coins of q can be written to
+func (q *ProviderQuotes) coinInfoMode() coinInfoMode {
+	switch {
+	case q.QuotesOnly:
+		return coinInfoEnsure
+	case q.MarketFields:
+		return coinInfoMarket
+	}
+	return coinInfoFull
+}
+
+// database returns the global *sql.DB instance or nil if the database is not connected (USE_DB=false).
+func (t *TickerService) database() *sql.DB {
+	if !db.IsConnected() {
+		return nil
+	}
+	return db.GetDatabase().GetDB()
+}
+
+// writeQuotes upserts every CoinInfo into coin_info and one coin_quote row per currency in its Quote map in a single transaction.
+// Each quote is also appended to coin_quote_history in the same transaction. mode selects the coin_info columns
+// written (see coinInfoMode).
+// Every coin is written under its own savepoint: a failing coin is rolled back to its savepoint and reported in
+// failed (keyed like data) while the other coins are still committed. Any other error (begin, commit, cancelled
+// context) rolls back the whole transaction so readers never see a mix of old and new prices.
+func writeQuotes(ctx context.Context, sqlDB *sql.DB, data map[string]CoinInfo, mode coinInfoMode) (failed map[string]error, err error) {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rollback is a no-op after a successful Commit
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	failed = make(map[string]error)
+	// Write coins in a stable order so concurrent syncs lock rows in the same order
+	for _, k := range sortedKeys(data) {
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT coin_write"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if coinErr := writeCoin(ctx, tx, data[k], mode); coinErr != nil {
+			failed[k] = coinErr
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT coin_write"); err != nil {
+				return nil, fmt.Errorf("failed to roll back coin: %w", err)
+			}
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT coin_write"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return failed, nil
+}
+
+// writeCoin upserts one coin into coin_info, coin_quote and coin_quote_history and updates its candles.
+// mode selects the coin_info columns written.
+func writeCoin(ctx context.Context, tx *sql.Tx, coin CoinInfo, mode coinInfoMode) error {
+	var coinID int
+	var err error
+	switch mode {
+	case coinInfoEnsure:
+		// A coin without a symbol would create a blank coin_info row, it is written once another provider created it
+		if coin.Symbol == "" {
+			err = tx.QueryRowContext(ctx, selectCoinInfoIDSQL, coin.CmcID).Scan(&coinID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("cmc_id %d: %w", coin.CmcID, ErrNoCoinInfo)
+			}
+			break
+		}
+		err = tx.QueryRowContext(ctx, ensureCoinInfoSQL, coin.CmcID, coin.Name, coin.Symbol).Scan(&coinID)
+	case coinInfoMarket:
+		err = tx.QueryRowContext(ctx, upsertMarketCoinInfoSQL,
+			coin.CmcID, coin.Name, coin.Symbol, coin.MaxSupply, coin.CirculatingSupply, coin.TotalSupply, coin.LastUpdated,
+		).Scan(&coinID)
+	default:
+		err = tx.QueryRowContext(ctx, upsertCoinInfoSQL,
+			coin.CmcID, coin.Name, coin.Symbol, coin.Slug, coin.CmcRank, coin.NumMarketPairs, pq.Array(coin.Tags),
+			coin.MaxSupply, coin.CirculatingSupply, coin.TotalSupply, coin.InfiniteSupply,
+			coin.SelfReportedCirculatingSupply, coin.SelfReportedMarketCap, coin.TvlRatio, coin.LastUpdated,
+		).Scan(&coinID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upsert coin_info for cmc_id %d: %w", coin.CmcID, err)
+	}
+
+	for _, currency := range sortedKeys(coin.Quote) {
+		quote := coin.Quote[currency]
+		_, err = tx.ExecContext(ctx, upsertCoinQuoteSQL,
+			coinID, currency, quote.Price, quote.MarketCap, quote.FullyDilutedMarketCap, quote.Volume24H,
+			quote.Volume24HReported, quote.VolumeChange24H, quote.PercentChange1H, quote.PercentChange24h,
+			quote.PercentChange7d, quote.PercentChange30d, quote.PercentChange60d, quote.PercentChange90d,
+			quote.Tvl, quote.LastUpdated,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to upsert coin_quote for cmc_id %d %s: %w", coin.CmcID, currency, err)
+		}
+
+		// History requires an upstream timestamp to dedupe on
+		if quote.LastUpdated.IsZero() {
+			continue
+		}
+		res, err := tx.ExecContext(ctx, insertCoinQuoteHistorySQL,
+			coinID, currency, quote.Price, quote.MarketCap, quote.FullyDilutedMarketCap, quote.Volume24H,
+			quote.Volume24HReported, quote.VolumeChange24H, quote.PercentChange1H, quote.PercentChange24h,
+			quote.PercentChange7d, quote.PercentChange30d, quote.PercentChange60d, quote.PercentChange90d,
+			quote.Tvl, quote.LastUpdated,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert coin_quote_history for cmc_id %d %s: %w", coin.CmcID, currency, err)
+		}
+
+		// Only new snapshots update candles so re-syncing the same upstream timestamp is a no-op
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		point := candlePoint{coinID: coinID, currency: currency, price: quote.Price, volume24h: quote.Volume24H, time: quote.LastUpdated.Time}
+		if err := updateCandles(ctx, tx, point); err != nil {
+			return fmt.Errorf("failed to update candles for cmc_id %d %s: %w", coin.CmcID, currency, err)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,492 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingDB is a database/sql connector that records every statement and transaction command in order. Statements
+// are recorded by their first three words (ex. "INSERT INTO coin_quote"). failOn returns an error to fail a statement
+// (or "COMMIT") instead of running it. rows returns the rows of a query, nil falls back to the default rows.
+// Tests the transaction handling of the write path without a database.
+type recordingDB struct {
+	mu     sync.Mutex
+	log    []string
+	nextID int64
+	failOn func(stmt string, args []driver.NamedValue) error
+	rows   func(stmt string) *recordingRows
+}
+
+// newRecordingDB returns a *sql.DB backed by r with a single connection so every statement runs on the same session
+func newRecordingDB(t *testing.T, r *recordingDB) *sql.DB {
+	sqlDB := sql.OpenDB(r)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+// statements returns the recorded statements
+func (r *recordingDB) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.log...)
+}
+
+// count returns the number of recorded statements equal to stmt
+func (r *recordingDB) count(stmt string) int {
+	n := 0
+	for _, s := range r.statements() {
+		if s == stmt {
+			n++
+		}
+	}
+	return n
+}
+
+// record logs stmt and returns the failOn error
+func (r *recordingDB) record(stmt string, args []driver.NamedValue) error {
+	r.mu.Lock()
+	r.log = append(r.log, stmt)
+	failOn := r.failOn
+	r.mu.Unlock()
+	if failOn != nil {
+		return failOn(stmt, args)
+	}
+	return nil
+}
+
+// Connect implements driver.Connector
+func (r *recordingDB) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{db: r}, nil
+}
+
+// Driver implements driver.Connector
+func (r *recordingDB) Driver() driver.Driver { return nil }
+
+// recordingConn implements driver.Conn, driver.ExecerContext, driver.QueryerContext and driver.ConnBeginTx
+type recordingConn struct {
+	db *recordingDB
+}
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recordingConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if err := c.db.record("BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return recordingTx{db: c.db}, nil
+}
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.record(statementName(query), args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// QueryContext returns the rows hook result, a new id for INSERT ... RETURNING id and no rows otherwise
+func (c *recordingConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.record(statementName(query), args); err != nil {
+		return nil, err
+	}
+	if c.db.rows != nil {
+		if rows := c.db.rows(statementName(query)); rows != nil {
+			return rows, nil
+		}
+	}
+	if !strings.Contains(query, "RETURNING id") {
+		return &recordingRows{}, nil
+	}
+	c.db.mu.Lock()
+	c.db.nextID++
+	id := c.db.nextID
+	c.db.mu.Unlock()
+	return &recordingRows{columns: []string{"id"}, values: [][]driver.Value{{id}}}, nil
+}
+
+// recordingTx implements driver.Tx
+type recordingTx struct {
+	db *recordingDB
+}
+
+func (tx recordingTx) Commit() error   { return tx.db.record("COMMIT", nil) }
+func (tx recordingTx) Rollback() error { return tx.db.record("ROLLBACK", nil) }
+
+// recordingRows implements driver.Rows
+type recordingRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *recordingRows) Columns() []string { return r.columns }
+func (r *recordingRows) Close() error      { return nil }
+func (r *recordingRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// statementName returns the first three words of query
+func statementName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) > 3 {
+		fields = fields[:3]
+	}
+	return strings.Join(fields, " ")
+}
+
+// testCoins returns coins keyed by CMC ID with one USD quote each
+func testCoins(lastUpdated time.Time, cmcIDs ...int) map[string]CoinInfo {
+	coins := make(map[string]CoinInfo, len(cmcIDs))
+	for _, id := range cmcIDs {
+		coins[fmt.Sprint(id)] = CoinInfo{
+			CmcID: id, Name: fmt.Sprintf("Coin %d", id), Symbol: fmt.Sprintf("C%d", id), Slug: fmt.Sprintf("coin-%d", id),
+			Quote: map[string]CoinQuote{"USD": {Price: "100.5", Volume24H: "1000", LastUpdated: CMCTime{Time: lastUpdated}}},
+		}
+	}
+	return coins
+}
+
+// TestWriteQuotesTransaction tests that all coins are written under their own savepoint in a single transaction
+func TestWriteQuotesTransaction(t *testing.T) {
+	r := &recordingDB{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	failed, err := writeQuotes(context.Background(), newRecordingDB(t, r), testCoins(now, 1, 2), coinInfoFull)
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("Expected no error, got %v (failed %v)", err, failed)
+	}
+	stmts := r.statements()
+	if stmts[0] != "BEGIN" || stmts[len(stmts)-1] != "COMMIT" || r.count("BEGIN") != 1 || r.count("COMMIT") != 1 {
+		t.Errorf("Expected one transaction around every statement, got %v", stmts)
+	}
+	if r.count("SAVEPOINT coin_write") != 2 || r.count("RELEASE SAVEPOINT coin_write") != 2 || r.count("ROLLBACK") != 0 {
+		t.Errorf("Expected one released savepoint per coin, got %v", stmts)
+	}
+	if r.count("INSERT INTO coin_info") != 2 || r.count("INSERT INTO coin_quote") != 2 {
+		t.Errorf("Expected coin_info and coin_quote upserts for both coins, got %v", stmts)
+	}
+}
+
+// TestWriteQuotesCoinFailure tests that a failing coin is rolled back to its savepoint and the other coins commit
+func TestWriteQuotesCoinFailure(t *testing.T) {
+	r := &recordingDB{failOn: func(stmt string, args []driver.NamedValue) error {
+		if stmt == "INSERT INTO coin_info" && args[0].Value == int64(2) {
+			return errors.New("constraint violation")
+		}
+		return nil
+	}}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	failed, err := writeQuotes(context.Background(), newRecordingDB(t, r), testCoins(now, 1, 2, 3), coinInfoFull)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(failed) != 1 || failed["2"] == nil {
+		t.Errorf("Expected coin 2 to fail, got %v", failed)
+	}
+	stmts := r.statements()
+	if r.count("ROLLBACK TO SAVEPOINT") != 1 || r.count("ROLLBACK") != 0 || stmts[len(stmts)-1] != "COMMIT" {
+		t.Errorf("Expected coin 2 rolled back to its savepoint and the transaction committed, got %v", stmts)
+	}
+	if r.count("INSERT INTO coin_quote") != 2 || r.count("RELEASE SAVEPOINT coin_write") != 2 {
+		t.Errorf("Expected coins 1 and 3 written, got %v", stmts)
+	}
+}
+
+// TestWriteQuotesRollback tests that errors outside a coin write roll back the whole transaction
+func TestWriteQuotesRollback(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("statement", func(t *testing.T) {
+		savepoints := 0
+		r := &recordingDB{failOn: func(stmt string, args []driver.NamedValue) error {
+			if stmt == "SAVEPOINT coin_write" {
+				if savepoints++; savepoints == 2 {
+					return errors.New("connection reset")
+				}
+			}
+			return nil
+		}}
+		if _, err := writeQuotes(context.Background(), newRecordingDB(t, r), testCoins(now, 1, 2), coinInfoFull); err == nil {
+			t.Fatal("Expected an error")
+		}
+		stmts := r.statements()
+		if stmts[len(stmts)-1] != "ROLLBACK" || r.count("COMMIT") != 0 {
+			t.Errorf("Expected the transaction rolled back, got %v", stmts)
+		}
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		r := &recordingDB{failOn: func(stmt string, args []driver.NamedValue) error {
+			if stmt == "COMMIT" {
+				return errors.New("serialization failure")
+			}
+			return nil
+		}}
+		failed, err := writeQuotes(context.Background(), newRecordingDB(t, r), testCoins(now, 1), coinInfoFull)
+		if err == nil || failed != nil {
+			t.Errorf("Expected a commit error and no per coin results, got %v (failed %v)", err, failed)
+		}
+	})
+}
+
+// testPostgres returns a connection to the Postgres database in TICKER_TEST_DATABASE_URL with every migration applied
+// in a new schema. Skips the test if TICKER_TEST_DATABASE_URL is not set.
+func testPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TICKER_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TICKER_TEST_DATABASE_URL not set")
+	}
+	sqlDB, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// One connection so the search_path applies to every statement
+	sqlDB.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("ticker_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		sqlDB.Exec("DROP SCHEMA " + schema + " CASCADE")
+		sqlDB.Close()
+	})
+	if _, err := sqlDB.Exec("CREATE SCHEMA " + schema + "; SET search_path TO " + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+
+	files, err := filepath.Glob("../../migrations/ticker/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("failed to find migrations: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		migration, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f, err)
+		}
+		if _, err := sqlDB.Exec(string(migration)); err != nil {
+			t.Fatalf("failed to apply %s: %v", filepath.Base(f), err)
+		}
+	}
+	return sqlDB
+}
+
+// countRows returns the number of rows in table
+func countRows(t *testing.T, sqlDB *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := sqlDB.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("failed to count %s: %v", table, err)
+	}
+	return n
+}
+
+// TestWriteQuotesPostgres tests savepoint isolation against Postgres: a coin that fails in the database is not
+// stored and the other coins are committed
+func TestWriteQuotesPostgres(t *testing.T) {
+	sqlDB := testPostgres(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	coins := testCoins(now, 1, 2, 3)
+	// symbol is VARCHAR(10), the coin_info insert of coin 2 fails
+	bad := coins["2"]
+	bad.Symbol = "SYMBOLTOOLONG"
+	coins["2"] = bad
+
+	failed, err := writeQuotes(context.Background(), sqlDB, coins, coinInfoFull)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(failed) != 1 || failed["2"] == nil {
+		t.Errorf("Expected coin 2 to fail, got %v", failed)
+	}
+	if n := countRows(t, sqlDB, "coin_info"); n != 2 {
+		t.Errorf("Expected 2 coin_info rows, got %d", n)
+	}
+	if n := countRows(t, sqlDB, "coin_quote"); n != 2 {
+		t.Errorf("Expected 2 coin_quote rows, got %d", n)
+	}
+}
+
+// TestWriteQuotesHistory tests that history rows are appended in the same transaction and under the same savepoint
+// as the coin_quote upsert
+func TestWriteQuotesHistory(t *testing.T) {
+	r := &recordingDB{failOn: func(stmt string, args []driver.NamedValue) error {
+		if stmt == "INSERT INTO coin_quote_history" {
+			return errors.New("disk full")
+		}
+		return nil
+	}}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	failed, err := writeQuotes(context.Background(), newRecordingDB(t, r), testCoins(now, 1), coinInfoFull)
+	if err != nil || failed["1"] == nil {
+		t.Fatalf("Expected coin 1 to fail, got %v (failed %v)", err, failed)
+	}
+	want := []string{
+		"BEGIN", "SAVEPOINT coin_write", "INSERT INTO coin_info", "INSERT INTO coin_quote", "INSERT INTO coin_quote_history",
+		"ROLLBACK TO SAVEPOINT", "COMMIT",
+	}
+	if got := r.statements(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected the coin_quote upsert rolled back with the history insert:\n got %v\nwant %v", got, want)
+	}
+}
+
+// TestWriteQuotesHistoryPostgres tests that re-writing the same upstream last_updated does not append history and a
+// new last_updated appends one row while coin_quote keeps the latest quote only
+func TestWriteQuotesHistoryPostgres(t *testing.T) {
+	sqlDB := testPostgres(t)
+	ctx := context.Background()
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if failed, err := writeQuotes(ctx, sqlDB, testCoins(first, 1), coinInfoFull); err != nil || len(failed) != 0 {
+			t.Fatalf("write %d: expected no error, got %v (failed %v)", i+1, err, failed)
+		}
+	}
+	if n := countRows(t, sqlDB, "coin_quote_history"); n != 1 {
+		t.Errorf("Expected 1 history row after re-writing the same last_updated, got %d", n)
+	}
+	var samples int
+	if err := sqlDB.QueryRow("SELECT sample_count FROM coin_candle WHERE resolution = '5m'").Scan(&samples); err != nil || samples != 1 {
+		t.Errorf("Expected 1 candle sample, got %d (%v)", samples, err)
+	}
+
+	if _, err := writeQuotes(ctx, sqlDB, testCoins(first.Add(time.Minute), 1), coinInfoFull); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n := countRows(t, sqlDB, "coin_quote_history"); n != 2 {
+		t.Errorf("Expected 2 history rows, got %d", n)
+	}
+	var quoteTime, historyTime time.Time
+	if err := sqlDB.QueryRow("SELECT last_updated FROM coin_quote").Scan(&quoteTime); err != nil {
+		t.Fatalf("failed to read coin_quote: %v", err)
+	}
+	if err := sqlDB.QueryRow("SELECT MAX(last_updated) FROM coin_quote_history").Scan(&historyTime); err != nil {
+		t.Fatalf("failed to read coin_quote_history: %v", err)
+	}
+	if !quoteTime.Equal(historyTime) || !quoteTime.Equal(first.Add(time.Minute)) {
+		t.Errorf("Expected coin_quote and the latest history row at %v, got %v and %v", first.Add(time.Minute), quoteTime, historyTime)
+	}
+}
+
+// TestWriteQuotesMarketFields tests that market fields results only write name, symbol, supplies and last_updated
+// to coin_info
+func TestWriteQuotesMarketFields(t *testing.T) {
+	var coinArgs []driver.NamedValue
+	r := &recordingDB{failOn: func(stmt string, args []driver.NamedValue) error {
+		if stmt == "INSERT INTO coin_info" {
+			coinArgs = args
+		}
+		return nil
+	}}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	coins := testCoins(now, 1)
+	coin := coins["1"]
+	rank := 7
+	coin.CmcRank, coin.CirculatingSupply, coin.TotalSupply = &rank, "19000000", "21000000"
+	coins["1"] = coin
+
+	if _, err := writeQuotes(context.Background(), newRecordingDB(t, r), coins, coinInfoMarket); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var got []any
+	for _, arg := range coinArgs {
+		got = append(got, arg.Value)
+	}
+	want := []any{int64(1), "Coin 1", "C1", nil, "19000000", "21000000"}
+	if len(got) != len(want)+1 {
+		t.Fatalf("Expected cmc_id, name, symbol, supplies and last_updated, got %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected argument %d to be %v, got %v", i+1, want[i], got[i])
+		}
+	}
+}
+
+// TestWriteQuotesEnsureWithoutSymbol tests that quotes only coins without a symbol never create a blank coin_info
+// row: a coin without coin_info row is skipped with ErrNoCoinInfo, a coin with a symbol is still created
+func TestWriteQuotesEnsureWithoutSymbol(t *testing.T) {
+	r := &recordingDB{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	coins := testCoins(now, 1, 2)
+	coin := coins["1"]
+	coin.Name, coin.Symbol = "", ""
+	coins["1"] = coin
+
+	failed, err := writeQuotes(context.Background(), newRecordingDB(t, r), coins, coinInfoEnsure)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(failed) != 1 || !errors.Is(failed["1"], ErrNoCoinInfo) {
+		t.Errorf("Expected cmc_id 1 skipped with ErrNoCoinInfo, got %v", failed)
+	}
+	if n := r.count("SELECT id FROM"); n != 1 {
+		t.Errorf("Expected one coin_info lookup, got %d", n)
+	}
+	if n := r.count("INSERT INTO coin_info"); n != 1 {
+		t.Errorf("Expected coin_info created for cmc_id 2 only, got %d inserts", n)
+	}
+	if n := r.count("INSERT INTO coin_quote"); n != 1 {
+		t.Errorf("Expected one coin_quote write, got %d", n)
+	}
+}
+
+// TestWriteQuotesMarketFieldsPostgres tests that a market fields write keeps the CMC only coin_info columns
+func TestWriteQuotesMarketFieldsPostgres(t *testing.T) {
+	sqlDB := testPostgres(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	coins := testCoins(now, 1)
+	coin := coins["1"]
+	rank, pairs := 1, 500
+	coin.CmcRank, coin.NumMarketPairs, coin.Tags, coin.CirculatingSupply = &rank, &pairs, []string{"pow"}, "19000000"
+	coins["1"] = coin
+	if _, err := writeQuotes(ctx, sqlDB, coins, coinInfoFull); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	market := testCoins(now.Add(time.Minute), 1)
+	coin = market["1"]
+	coin.Name, coin.Slug, coin.CirculatingSupply = "Bitcoin", "", "19000100"
+	market["1"] = coin
+	if failed, err := writeQuotes(ctx, sqlDB, market, coinInfoMarket); err != nil || len(failed) != 0 {
+		t.Fatalf("Expected no error, got %v (failed %v)", err, failed)
+	}
+
+	var name, slug string
+	var supply float64
+	var cmcRank, numPairs sql.NullInt64
+	var tags []byte
+	err := sqlDB.QueryRow("SELECT name, slug, cmc_rank, num_market_pairs, tags, circulating_supply FROM coin_info WHERE cmc_id = 1").
+		Scan(&name, &slug, &cmcRank, &numPairs, &tags, &supply)
+	if err != nil {
+		t.Fatalf("failed to read coin_info: %v", err)
+	}
+	if name != "Bitcoin" || supply != 19000100 {
+		t.Errorf("Expected name and supply updated, got %q %v", name, supply)
+	}
+	if slug != "coin-1" || cmcRank.Int64 != 1 || numPairs.Int64 != 500 || string(tags) != "{pow}" {
+		t.Errorf("Expected the CMC only columns kept, got slug %q rank %v pairs %v tags %s", slug, cmcRank, numPairs, tags)
+	}
+}
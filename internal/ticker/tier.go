@@ -0,0 +1,79 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// Coins are polled in tiers (ex. hot every 30s, normal every 2m, cold every 15m) to spend credits where they matter.
+// Tier membership comes from tracked_coins.tier so every coin is in exactly one tier. Coins with a tier that is not
+// configured are polled with the default tier. main runs one SyncTier loop per tier on its own interval.
+
+// SyncTier syncs the enabled tracked coins of a single tier
+func (t *TickerService) SyncTier(ctx context.Context, tier string) (*SyncReport, error) {
+	if !t.tiers[tier] {
+		err := fmt.Errorf("unknown tier %q", tier)
+		return t.emptyReport(), err
+	}
+
+	// Listings mode does not use tracked coins, the default tier loop syncs the listings
+	if t.mode == SyncModeListings {
+		if tier != t.defaultTier {
+			t.logger.Info("Listings mode - skipping tier", "tier", tier)
+			return t.emptyReport(), nil
+		}
+		return t.syncListings(ctx)
+	}
+
+	byTier, err := t.trackedIDsByTier(ctx)
+	if err != nil {
+		t.logger.Error("failed to get tracked coins", "tier", tier, "error", err)
+		return t.emptyReport(), err
+	}
+	ids := byTier[tier]
+	if len(ids) == 0 {
+		t.logger.Info("No tracked coins in tier - skipping upstream call", "tier", tier)
+	}
+	return t.runSync(ctx, ids)
+}
+
+// trackedIDsByTier returns the CMC ID's of the enabled tracked coins grouped by tier
+func (t *TickerService) trackedIDsByTier(ctx context.Context) (map[string][]string, error) {
+	if t.coins == nil {
+		return nil, errors.New("coin service not provided")
+	}
+	tracked, err := t.coins.GetTrackedCoins(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byTier := make(map[string][]string)
+	for _, coin := range tracked {
+		tier := coin.Tier
+		if !t.tiers[tier] {
+			tier = t.defaultTier
+		}
+		byTier[tier] = append(byTier[tier], strconv.Itoa(coin.CmCID))
+	}
+	return byTier, nil
+}
+
+// buildTiers returns the set of configured tier names and the tier used for coins with an unknown tier.
+// Falls back to the first configured tier if defaultTier is not configured.
+func buildTiers(tiers []config.TierSettings, defaultTier string) (map[string]bool, string) {
+	names := make(map[string]bool, len(tiers))
+	for _, tier := range tiers {
+		names[tier.Name] = true
+	}
+	if !names[defaultTier] && len(tiers) > 0 {
+		defaultTier = tiers[0].Name
+	}
+	if len(names) == 0 {
+		names[defaultTier] = true
+	}
+	return names, defaultTier
+}
@@ -0,0 +1,68 @@
+package ticker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestSyncTier tests that each tier only fetches its own coins and unknown tiers fall back to the default tier
+func TestSyncTier(t *testing.T) {
+	var mu sync.Mutex
+	var gotIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		gotIDs = append(gotIDs, r.URL.Query().Get("id"))
+		mu.Unlock()
+		w.Write([]byte(`{"status":{"error_code":0},"data":{}}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.AppConfig{
+		CMC: config.CMCSettings{QuotesURL: server.URL},
+		Interval: config.IntervalSettings{
+			Tiers: []config.TierSettings{
+				{Name: "hot", Interval: 30 * time.Second},
+				{Name: "normal", Interval: 2 * time.Minute},
+				{Name: "cold", Interval: 15 * time.Minute},
+			},
+			DefaultTier: "normal",
+		},
+	}
+	coinService := &fakeCoins{
+		ids:   []int{1, 1027, 5994, 8916},
+		tiers: map[int]string{1: "hot", 1027: "hot", 5994: "cold", 8916: "unknown"},
+	}
+	service := NewTickerService(cfg, coinService, testLogger(), server.Client())
+
+	tests := []struct {
+		tier    string
+		wantIDs string
+	}{
+		{"hot", "1,1027"},
+		{"cold", "5994"},
+		{"normal", "8916"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tier, func(t *testing.T) {
+			gotIDs = nil
+			if _, err := service.SyncTier(context.Background(), tt.tier); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(gotIDs) != 1 || gotIDs[0] != tt.wantIDs {
+				t.Errorf("Expected id=%s, got %v", tt.wantIDs, gotIDs)
+			}
+		})
+	}
+
+	t.Run("unconfigured tier", func(t *testing.T) {
+		if _, err := service.SyncTier(context.Background(), "warm"); err == nil {
+			t.Error("Expected error for unconfigured tier")
+		}
+	})
+}
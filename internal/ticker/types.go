@@ -1,9 +1,17 @@
 package ticker
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // All JSON fields that can be null in CMC API response are pointers allowing null values to avoid
 // unmarshalling errors or setting zero values instead of nil.
 // Always check documentation when adding new fields.
 // Always check for nil if trying to dereference a pointer to avoid runtime errors (panic).
+// Numeric fields are Decimal so prices and supplies reach the NUMERIC columns without rounding (see decimal.go).
 
 // CMCResponse holds the response from the CMC API.
 type CMCResponse struct {
@@ -11,9 +19,75 @@ type CMCResponse struct {
 	Data   map[string]CoinInfo `json:"data"`
 }
 
+// ListingsResponse holds the response from the CMC listings endpoint (/v1/cryptocurrency/listings/latest).
+// Same coin and quote fields as CMCResponse but Data is a list sorted by the requested sort.
+type ListingsResponse struct {
+	Status Status     `json:"status"`
+	Data   []CoinInfo `json:"data"`
+}
+
+// HistoricalResponse holds the response from the CMC historical quotes endpoint (/v2/cryptocurrency/quotes/historical).
+// Data map key is the CMC ID when querying by id.
+type HistoricalResponse struct {
+	Status Status                    `json:"status"`
+	Data   map[string]HistoricalCoin `json:"data"`
+}
+
+// HistoricalCoin holds the historical quotes of a coin from CMC API.
+type HistoricalCoin struct {
+	CmcID  int               `json:"id"`
+	Name   string            `json:"name"`
+	Symbol string            `json:"symbol"`
+	Quotes []HistoricalQuote `json:"quotes"`
+}
+
+// HistoricalQuote holds one historical data point. Quote map key is the convert symbol ("USD").
+// CoinQuote.LastUpdated is empty in historical quotes, Timestamp is the time of the data point.
+type HistoricalQuote struct {
+	Timestamp CMCTime              `json:"timestamp"`
+	Quote     map[string]CoinQuote `json:"quote"`
+}
+
+// GlobalMetricsResponse holds the response from the CMC global metrics endpoint (/v1/global-metrics/quotes/latest).
+type GlobalMetricsResponse struct {
+	Status Status        `json:"status"`
+	Data   GlobalMetrics `json:"data"`
+}
+
+// GlobalMetrics holds the global market metrics from CMC API, including GlobalQuote data.
+// DeFi, stablecoin and derivatives totals are in USD regardless of the convert targets.
+type GlobalMetrics struct {
+	ActiveCryptocurrencies int                    `json:"active_cryptocurrencies"`
+	TotalCryptocurrencies  int                    `json:"total_cryptocurrencies"`
+	ActiveMarketPairs      int                    `json:"active_market_pairs"`
+	ActiveExchanges        int                    `json:"active_exchanges"`
+	TotalExchanges         int                    `json:"total_exchanges"`
+	BtcDominance           Decimal                `json:"btc_dominance"`
+	EthDominance           Decimal                `json:"eth_dominance"`
+	DefiMarketCap          Decimal                `json:"defi_market_cap"`
+	DefiVolume24H          Decimal                `json:"defi_volume_24h"`
+	StablecoinMarketCap    Decimal                `json:"stablecoin_market_cap"`
+	StablecoinVolume24H    Decimal                `json:"stablecoin_volume_24h"`
+	DerivativesVolume24H   Decimal                `json:"derivatives_volume_24h"`
+	LastUpdated            CMCTime                `json:"last_updated"`
+	Quote                  map[string]GlobalQuote `json:"quote"` // map key is the convert symbol ("USD") or convert_id ("2781")
+}
+
+// GlobalQuote holds the global market totals from CMC API in one currency.
+type GlobalQuote struct {
+	TotalMarketCap                          Decimal `json:"total_market_cap"`
+	TotalVolume24H                          Decimal `json:"total_volume_24h"`
+	TotalVolume24HReported                  Decimal `json:"total_volume_24h_reported"`
+	AltcoinMarketCap                        Decimal `json:"altcoin_market_cap"`
+	AltcoinVolume24H                        Decimal `json:"altcoin_volume_24h"`
+	TotalMarketCapYesterdayPercentageChange Decimal `json:"total_market_cap_yesterday_percentage_change"`
+	TotalVolume24HYesterdayPercentageChange Decimal `json:"total_volume_24h_yesterday_percentage_change"`
+	LastUpdated                             CMCTime `json:"last_updated"`
+}
+
 // Status holds the response status from CMC API.
 type Status struct {
-	Timestamp    string  `json:"timestamp"`
+	Timestamp    CMCTime `json:"timestamp"`
 	ErrorCode    int     `json:"error_code"`
 	ErrorMessage *string `json:"error_message"`
 	Elapsed      int     `json:"elapsed"`
@@ -22,25 +96,165 @@ type Status struct {
 }
 
 // CoinInfo holds the coin related information from CMC API, including CoinQuote data.
+// CoinInfo and CoinQuote are also the canonical model returned by every QuoteProvider (see provider.go).
+// Fields marked (aux) are only returned when requested in the aux query parameter (CMC_AUX).
 type CoinInfo struct {
-	CmcID             int                  `json:"id"` // CMC ID is recommended by CMC API documentation
-	Name              string               `json:"name"`
-	Symbol            string               `json:"symbol"`
-	Slug              string               `json:"slug"`
-	CirculatingSupply float64              `json:"circulating_supply"`
-	TotalSupply       float64              `json:"total_supply"`
-	LastUpdated       string               `json:"last_updated"`
-	Quote             map[string]CoinQuote `json:"quote"` // map key is "USD" from CMC API Response
+	CmcID                         int                  `json:"id"` // CMC ID is recommended by CMC API documentation
+	Name                          string               `json:"name"`
+	Symbol                        string               `json:"symbol"`
+	Slug                          string               `json:"slug"`
+	CmcRank                       *int                 `json:"cmc_rank"`         // (aux)
+	NumMarketPairs                *int                 `json:"num_market_pairs"` // (aux)
+	Tags                          Tags                 `json:"tags"`             // (aux)
+	MaxSupply                     *Decimal             `json:"max_supply"`       // (aux) null if no max supply
+	CirculatingSupply             Decimal              `json:"circulating_supply"`
+	TotalSupply                   Decimal              `json:"total_supply"`
+	InfiniteSupply                bool                 `json:"infinite_supply"`
+	SelfReportedCirculatingSupply *Decimal             `json:"self_reported_circulating_supply"`
+	SelfReportedMarketCap         *Decimal             `json:"self_reported_market_cap"`
+	TvlRatio                      *Decimal             `json:"tvl_ratio"`
+	LastUpdated                   CMCTime              `json:"last_updated"`
+	Quote                         map[string]CoinQuote `json:"quote"` // map key is the convert symbol ("USD") or convert_id ("2781")
 }
 
-// CoinQuote holds the quote data for a coin from CMC API. Only uses USD for map key in CoinInfo.
+// CoinQuote holds the quote data for a coin from CMC API in one currency. One CoinQuote per convert target in CoinInfo.
 type CoinQuote struct {
-	Price                 float64 `json:"price"`
-	MarketCap             float64 `json:"market_cap"`
-	FullyDilutedMarketCap float64 `json:"fully_diluted_market_cap"`
-	Volume24H             float64 `json:"volume_24h"`
-	PercentChange1H       float64 `json:"percent_change_1h"`
-	PercentChange24h      float64 `json:"percent_change_24h"`
-	PercentChange7d       float64 `json:"percent_change_7d"`
-	LastUpdated           string  `json:"last_updated"`
+	Price                 Decimal  `json:"price"`
+	MarketCap             Decimal  `json:"market_cap"`
+	FullyDilutedMarketCap Decimal  `json:"fully_diluted_market_cap"`
+	Volume24H             Decimal  `json:"volume_24h"`
+	Volume24HReported     *Decimal `json:"volume_24h_reported"` // (aux)
+	VolumeChange24H       Decimal  `json:"volume_change_24h"`
+	PercentChange1H       Decimal  `json:"percent_change_1h"`
+	PercentChange24h      Decimal  `json:"percent_change_24h"`
+	PercentChange7d       Decimal  `json:"percent_change_7d"`
+	PercentChange30d      Decimal  `json:"percent_change_30d"`
+	PercentChange60d      Decimal  `json:"percent_change_60d"`
+	PercentChange90d      Decimal  `json:"percent_change_90d"`
+	Tvl                   *Decimal `json:"tvl"`
+	LastUpdated           CMCTime  `json:"last_updated"`
+}
+
+// Tags holds the tag slugs of a coin. CMC v2 endpoints return tags as objects ({"slug": "pow", ...})
+// while v1 endpoints return plain strings, both are decoded into a list of slugs.
+type Tags []string
+
+// UnmarshalJSON decodes tags from a list of strings or a list of tag objects
+func (t *Tags) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	tags := make(Tags, 0, len(raw))
+	for _, r := range raw {
+		var slug string
+		if err := json.Unmarshal(r, &slug); err == nil {
+			tags = append(tags, slug)
+			continue
+		}
+		var obj struct {
+			Slug string `json:"slug"`
+		}
+		if err := json.Unmarshal(r, &obj); err != nil {
+			return err
+		}
+		tags = append(tags, obj.Slug)
+	}
+	*t = tags
+	return nil
+}
+
+// cmcTimeLayout is the CMC timestamp format (ISO 8601 with milliseconds, ex. 2024-01-01T00:00:00.000Z)
+const cmcTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// CMCTime holds a CMC timestamp in UTC. The zero value is a null or empty timestamp (check IsZero).
+// Decodes from CMC JSON (RFC 3339 with or without fractional seconds, null or "") and is stored in TIMESTAMP
+// columns as UTC (NULL when zero).
+type CMCTime struct {
+	time.Time
+}
+
+// parseCMCTime parses a CMC timestamp. An empty string is the zero CMCTime.
+func parseCMCTime(s string) (CMCTime, error) {
+	if s == "" {
+		return CMCTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return CMCTime{}, fmt.Errorf("invalid CMC timestamp %q: %w", s, err)
+	}
+	return CMCTime{Time: t.UTC()}, nil
+}
+
+// String returns the timestamp in CMC format or an empty string if zero
+func (t CMCTime) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(cmcTimeLayout)
+}
+
+// UnmarshalJSON decodes a CMC timestamp string. null and "" decode to the zero CMCTime.
+func (t *CMCTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = CMCTime{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid CMC timestamp %s: %w", data, err)
+	}
+	parsed, err := parseCMCTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// MarshalJSON encodes the timestamp in CMC format or null if zero
+func (t CMCTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// Value implements driver.Valuer. Zero timestamps are stored as NULL.
+func (t CMCTime) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC(), nil
+}
+
+// Scan implements sql.Scanner for nullable TIMESTAMP columns
+func (t *CMCTime) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = CMCTime{}
+	case time.Time:
+		*t = CMCTime{Time: v.UTC()}
+	case []byte:
+		return t.scanString(string(v))
+	case string:
+		return t.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into CMCTime", src)
+	}
+	return nil
+}
+
+// scanString parses a timestamp returned as text (RFC 3339 or the Postgres text format)
+func (t *CMCTime) scanString(s string) error {
+	if parsed, err := parseCMCTime(s); err == nil {
+		*t = parsed
+		return nil
+	}
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999999", s)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into CMCTime: %w", s, err)
+	}
+	*t = CMCTime{Time: parsed.UTC()}
+	return nil
 }
@@ -0,0 +1,215 @@
+package ticker
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestCoinInfoAuxFields tests decoding of aux fields, nullable fields and both tag formats
+func TestCoinInfoAuxFields(t *testing.T) {
+	body := `{
+		"id": 1, "symbol": "BTC", "cmc_rank": 1, "num_market_pairs": 11000,
+		"tags": [{"slug": "mineable", "name": "Mineable"}, {"slug": "pow", "name": "PoW"}],
+		"max_supply": 21000000, "self_reported_market_cap": null, "tvl_ratio": null,
+		"quote": {"USD": {"price": 50000, "percent_change_90d": 12.5, "volume_24h_reported": null, "tvl": null}}
+	}`
+
+	var coin CoinInfo
+	if err := json.Unmarshal([]byte(body), &coin); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if coin.CmcRank == nil || *coin.CmcRank != 1 {
+		t.Errorf("Expected cmc_rank 1, got %v", coin.CmcRank)
+	}
+	if coin.MaxSupply == nil || *coin.MaxSupply != "21000000" {
+		t.Errorf("Expected max_supply 21000000, got %v", coin.MaxSupply)
+	}
+	if coin.SelfReportedMarketCap != nil {
+		t.Errorf("Expected nil self_reported_market_cap, got %v", *coin.SelfReportedMarketCap)
+	}
+	if !reflect.DeepEqual(coin.Tags, Tags{"mineable", "pow"}) {
+		t.Errorf("Expected tags [mineable pow], got %v", coin.Tags)
+	}
+	if quote := coin.Quote["USD"]; quote.PercentChange90d != "12.5" || quote.Tvl != nil {
+		t.Errorf("Unexpected quote fields: %+v", quote)
+	}
+
+	var tags Tags
+	if err := json.Unmarshal([]byte(`["mineable", "pow"]`), &tags); err != nil || !reflect.DeepEqual(tags, Tags{"mineable", "pow"}) {
+		t.Errorf("Expected string tags to decode, got %v (%v)", tags, err)
+	}
+}
+
+// cmcTime parses a CMC timestamp for test fixtures
+func cmcTime(s string) CMCTime {
+	ts, err := parseCMCTime(s)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+// TestCMCTime tests decoding CMC timestamps, null and empty values and the JSON and SQL round trips
+func TestCMCTime(t *testing.T) {
+	var status Status
+	body := `{"timestamp":"2024-03-01T12:34:56.789Z"}`
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := time.Date(2024, 3, 1, 12, 34, 56, 789000000, time.UTC)
+	if !status.Timestamp.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, status.Timestamp)
+	}
+
+	t.Run("null and empty", func(t *testing.T) {
+		var quote CoinQuote
+		for _, body := range []string{`{"last_updated":null}`, `{"last_updated":""}`, `{}`} {
+			if err := json.Unmarshal([]byte(body), &quote); err != nil || !quote.LastUpdated.IsZero() {
+				t.Errorf("%s: expected zero timestamp, got %v (%v)", body, quote.LastUpdated, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var quote CoinQuote
+		if err := json.Unmarshal([]byte(`{"last_updated":"yesterday"}`), &quote); err == nil {
+			t.Error("Expected an error for an invalid timestamp")
+		}
+	})
+
+	t.Run("JSON round trip", func(t *testing.T) {
+		data, err := json.Marshal(status.Timestamp)
+		if err != nil || string(data) != `"2024-03-01T12:34:56.789Z"` {
+			t.Errorf("Expected CMC format, got %s (%v)", data, err)
+		}
+		if data, _ := json.Marshal(CMCTime{}); string(data) != "null" {
+			t.Errorf("Expected null for zero timestamp, got %s", data)
+		}
+	})
+
+	t.Run("SQL round trip", func(t *testing.T) {
+		if v, err := (CMCTime{}).Value(); v != nil || err != nil {
+			t.Errorf("Expected NULL for zero timestamp, got %v (%v)", v, err)
+		}
+		v, err := status.Timestamp.Value()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var scanned CMCTime
+		if err := scanned.Scan(v); err != nil || !scanned.Equal(want) {
+			t.Errorf("Expected %v, got %v (%v)", want, scanned, err)
+		}
+		if err := scanned.Scan([]byte("2024-03-01 12:34:56.789")); err != nil || !scanned.Equal(want) {
+			t.Errorf("Expected %v from text, got %v (%v)", want, scanned, err)
+		}
+		if err := scanned.Scan(nil); err != nil || !scanned.IsZero() {
+			t.Errorf("Expected zero timestamp from NULL, got %v (%v)", scanned, err)
+		}
+	})
+
+	t.Run("ordering", func(t *testing.T) {
+		if !cmcTime("2024-01-01T00:00:01.000Z").After(cmcTime("2024-01-01T00:00:00.999Z").Time) {
+			t.Error("Expected later timestamp to be after earlier timestamp")
+		}
+	})
+}
+
+// TestDecimal tests exact decoding, null handling, the JSON and SQL round trips and exact comparison
+func TestDecimal(t *testing.T) {
+	var quote CoinQuote
+	body := `{"price": 0.000000012345678901234567, "market_cap": 123456789012345678901234.5, "volume_24h": null}`
+	if err := json.Unmarshal([]byte(body), &quote); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if quote.Price != "0.000000012345678901234567" {
+		t.Errorf("Expected price without rounding, got %s", quote.Price)
+	}
+	if quote.MarketCap != "123456789012345678901234.5" {
+		t.Errorf("Expected market cap without rounding, got %s", quote.MarketCap)
+	}
+	if !quote.Volume24H.IsNull() {
+		t.Errorf("Expected null volume, got %s", quote.Volume24H)
+	}
+
+	t.Run("JSON round trip", func(t *testing.T) {
+		data, err := json.Marshal(quote.Price)
+		if err != nil || string(data) != "0.000000012345678901234567" {
+			t.Errorf("Expected exact JSON number, got %s (%v)", data, err)
+		}
+		if data, _ := json.Marshal(quote.Volume24H); string(data) != "null" {
+			t.Errorf("Expected null, got %s", data)
+		}
+	})
+
+	t.Run("SQL round trip", func(t *testing.T) {
+		v, err := quote.Price.Value()
+		if err != nil || v != "0.000000012345678901234567" {
+			t.Errorf("Expected exact text value, got %v (%v)", v, err)
+		}
+		if v, _ := quote.Volume24H.Value(); v != nil {
+			t.Errorf("Expected NULL, got %v", v)
+		}
+		var scanned Decimal
+		if err := scanned.Scan([]byte("0.000000012345678901")); err != nil || scanned != "0.000000012345678901" {
+			t.Errorf("Expected exact scan, got %s (%v)", scanned, err)
+		}
+	})
+
+	t.Run("compare", func(t *testing.T) {
+		// Equal as float64 but not as decimals
+		a, b := Decimal("0.10000000000000000001"), Decimal("0.1")
+		if a.Cmp(b) != 1 || b.Cmp(a) != -1 || Decimal("50000.00").Cmp("50000") != 0 {
+			t.Error("Expected exact decimal comparison")
+		}
+	})
+
+	t.Run("arithmetic", func(t *testing.T) {
+		if got := Decimal("12.5").Mul("67187.33", 8); got != "839841.62500000" {
+			t.Errorf("Expected exact product, got %s", got)
+		}
+		if got := percentChange("64000", "67200"); got != "5.00000000" {
+			t.Errorf("Expected 5%% change, got %s", got)
+		}
+		if !Decimal("").Mul("1", 8).IsNull() || !percentChange("0", "1").IsNull() {
+			t.Error("Expected null for null operands and a zero open")
+		}
+	})
+}
+
+// TestFitNumerics tests that values too large for their column are stored as NULL and reported
+func TestFitNumerics(t *testing.T) {
+	tooLarge := Decimal("1e40")
+	data := map[string]CoinInfo{
+		"1": {CmcID: 1, Symbol: "MEME", CirculatingSupply: "999999999999999999999999", TotalSupply: tooLarge,
+			Quote: map[string]CoinQuote{
+				"USD": {Price: "0.000000000001", MarketCap: "1000", PercentChange24h: "1e25"},
+			}},
+		"2": {CmcID: 2, Symbol: "BAD", Quote: map[string]CoinQuote{"USD": {Price: tooLarge}}},
+	}
+
+	fitted, overflows, invalid := fitNumerics(data)
+
+	coin := fitted["1"]
+	if coin.CirculatingSupply != "999999999999999999999999" || !coin.TotalSupply.IsNull() {
+		t.Errorf("Expected supply kept and total supply nulled, got %s and %s", coin.CirculatingSupply, coin.TotalSupply)
+	}
+	if quote := coin.Quote["USD"]; quote.Price != "0.000000000001" || !quote.PercentChange24h.IsNull() {
+		t.Errorf("Expected price kept and percent change nulled, got %+v", quote)
+	}
+	if len(overflows) != 3 {
+		t.Errorf("Expected 3 overflows, got %+v", overflows)
+	}
+	if _, ok := fitted["2"]; ok || len(invalid) != 1 || invalid[0].CmcID != 2 {
+		t.Errorf("Expected cmc_id 2 invalid, got %+v", invalid)
+	}
+
+	// NUMERIC(30, 8) holds 22 integer digits, a value that rounds up to 10^22 does not fit
+	if !Decimal("9999999999999999999999.99999999").fits(percentNumeric) {
+		t.Error("Expected largest NUMERIC(30, 8) value to fit")
+	}
+	if Decimal("9999999999999999999999.999999995").fits(percentNumeric) {
+		t.Error("Expected value rounding up to 10^22 to not fit")
+	}
+}
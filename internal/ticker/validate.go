@@ -0,0 +1,172 @@
+package ticker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// Validation runs between the provider fetch and UpdateDB. Every quote is checked against the configured rules
+// (config.ValidationSettings) and rejected quotes are written to coin_quote_quarantine with the reason instead of
+// overwriting good data in coin_quote. A coin is reported as invalid only when all of its quotes are rejected.
+// The price move rule compares against the last accepted price, which does not follow a real large move. The move is
+// accepted once PriceMoveConfirmations consecutive quarantined quotes agree with the new price. Quarantined quotes
+// with the same price are stored once, so confirmations count distinct prices since the last stored quote, not syncs.
+
+// Errors recorded as the rejection reason
+var (
+	ErrNonPositivePrice  = errors.New("non-positive price")
+	ErrNonFinite         = errors.New("non-finite value")
+	ErrPriceMove         = errors.New("price move exceeds limit")
+	ErrMarketCapMismatch = errors.New("market cap inconsistent with price * circulating supply")
+)
+
+// insertQuarantineSQL stores a rejected quote once per upstream last_updated and once per price since the last
+// stored quote. Binance and Kraken stamp quotes with the fetch time, the price dedupe keeps a price that stays
+// rejected from adding a row (and a price move confirmation) on every sync.
+const insertQuarantineSQL = `
+INSERT INTO coin_quote_quarantine (cmc_id, symbol, currency, price, previous_price, reason, payload, last_updated)
+SELECT $1::int, $2::varchar, $3::varchar, $4::numeric, $5::numeric, $6::text, $7::jsonb, $8::timestamp
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM coin_quote_quarantine q
+    LEFT JOIN coin_info ci ON ci.cmc_id = q.cmc_id
+    LEFT JOIN coin_quote cq ON cq.coin_id = ci.id AND cq.currency = q.currency
+    WHERE q.cmc_id = $1::int AND q.currency = $3::varchar AND q.price = $4::numeric
+        AND (cq.last_updated IS NULL OR q.last_updated > cq.last_updated)
+)
+ON CONFLICT ON CONSTRAINT unique_coin_quote_quarantine DO NOTHING`
+
+// QuoteRejection holds a quote that failed validation and the reason it was rejected.
+type QuoteRejection struct {
+	CmcID         int
+	Symbol        string
+	Currency      string
+	Quote         CoinQuote
+	PreviousPrice float64 // last stored price, 0 if none
+	Err           error
+}
+
+// validateQuotes checks every quote against rules. Returns the coins with their accepted quotes, the rejected quotes
+// and an invalid outcome for each coin left without quotes.
+func validateQuotes(data map[string]CoinInfo, stored map[quoteKey]storedQuote, rules config.ValidationSettings) (map[string]CoinInfo, []QuoteRejection, []CoinOutcome) {
+	accepted := make(map[string]CoinInfo, len(data))
+	var rejected []QuoteRejection
+	var outcomes []CoinOutcome
+
+	for _, k := range sortedKeys(data) {
+		coin := data[k]
+		quotes := make(map[string]CoinQuote, len(coin.Quote))
+		var lastErr error
+		for _, currency := range sortedKeys(coin.Quote) {
+			quote := coin.Quote[currency]
+			prev := stored[quoteKey{cmcID: coin.CmcID, currency: currency}]
+			if err := validateQuote(coin, quote, prev, rules); err != nil {
+				rejected = append(rejected, QuoteRejection{
+					CmcID: coin.CmcID, Symbol: coin.Symbol, Currency: currency,
+					Quote: quote, PreviousPrice: prev.price, Err: err,
+				})
+				lastErr = fmt.Errorf("%s: %w", currency, err)
+				continue
+			}
+			quotes[currency] = quote
+		}
+		if len(quotes) == 0 {
+			outcomes = append(outcomes, CoinOutcome{CmcID: coin.CmcID, Symbol: coin.Symbol, Status: OutcomeInvalid, Err: lastErr})
+			continue
+		}
+		coin.Quote = quotes
+		accepted[k] = coin
+	}
+	return accepted, rejected, outcomes
+}
+
+// validateQuote applies the configured rules to a single quote. prev is the last stored quote (zero if none).
+// Rules work on float64 approximations of the exact values.
+func validateQuote(coin CoinInfo, quote CoinQuote, prev storedQuote, rules config.ValidationSettings) error {
+	price := quote.Price.Float64()
+	marketCap := quote.MarketCap.Float64()
+	supply := coin.CirculatingSupply.Float64()
+
+	if rules.RejectNonFinite {
+		for _, v := range []Decimal{
+			quote.Price, quote.MarketCap, quote.FullyDilutedMarketCap, quote.Volume24H,
+			quote.PercentChange1H, quote.PercentChange24h, quote.PercentChange7d,
+			coin.CirculatingSupply, coin.TotalSupply,
+		} {
+			if f := v.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+				return ErrNonFinite
+			}
+		}
+	}
+
+	if rules.RejectNonPositivePrice && price <= 0 {
+		return fmt.Errorf("%w: %v", ErrNonPositivePrice, quote.Price)
+	}
+
+	if rules.MaxPriceMovePct > 0 && prev.price > 0 {
+		move := priceMovePct(prev.price, price)
+		if move > rules.MaxPriceMovePct && !confirmedMove(price, prev.rejectedMoves, rules) {
+			return fmt.Errorf("%w: %.2f%% from %v to %v", ErrPriceMove, move, prev.price, quote.Price)
+		}
+	}
+
+	// CMC reports a zero market cap for coins without a verified circulating supply, skip those
+	if rules.MarketCapTolerancePct > 0 && marketCap > 0 && supply > 0 {
+		expected := price * supply
+		diff := math.Abs(marketCap-expected) / marketCap * 100
+		if diff > rules.MarketCapTolerancePct {
+			return fmt.Errorf("%w: market cap %v, price * supply %v", ErrMarketCapMismatch, quote.MarketCap, expected)
+		}
+	}
+	return nil
+}
+
+// priceMovePct returns the absolute percent move from prev to price
+func priceMovePct(prev, price float64) float64 {
+	return math.Abs(price-prev) / prev * 100
+}
+
+// confirmedMove reports whether the last PriceMoveConfirmations quarantined price moves (newest first) are all within
+// MaxPriceMovePct of price, i.e. upstream has consistently reported the new price level
+func confirmedMove(price float64, rejectedMoves []float64, rules config.ValidationSettings) bool {
+	n := rules.PriceMoveConfirmations
+	if n <= 0 || len(rejectedMoves) < n {
+		return false
+	}
+	for _, rejected := range rejectedMoves[:n] {
+		if rejected <= 0 || priceMovePct(rejected, price) > rules.MaxPriceMovePct {
+			return false
+		}
+	}
+	return true
+}
+
+// quarantineQuotes writes rejected quotes to coin_quote_quarantine. Failures are returned but never block the sync.
+func quarantineQuotes(ctx context.Context, sqlDB *sql.DB, rejected []QuoteRejection) error {
+	var errs []error
+	for _, r := range rejected {
+		payload, err := json.Marshal(r.Quote)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to marshal quarantined quote for cmc_id %d %s: %w", r.CmcID, r.Currency, err))
+			continue
+		}
+		var previous any
+		if r.PreviousPrice > 0 {
+			previous = r.PreviousPrice
+		}
+		_, err = sqlDB.ExecContext(ctx, insertQuarantineSQL,
+			r.CmcID, r.Symbol, r.Currency, fitOrNil(r.Quote.Price, priceNumeric), previous, r.Err.Error(), payload,
+			r.Quote.LastUpdated,
+		)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to quarantine quote for cmc_id %d %s: %w", r.CmcID, r.Currency, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,179 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jdbdev/moonramp-ticker/config"
+)
+
+// TestValidateQuote tests each validation rule against a single quote
+func TestValidateQuote(t *testing.T) {
+	rules := config.ValidationSettings{
+		RejectNonPositivePrice: true,
+		RejectNonFinite:        true,
+		MaxPriceMovePct:        50,
+		MarketCapTolerancePct:  5,
+	}
+	coin := CoinInfo{CmcID: 1, Symbol: "BTC", CirculatingSupply: "100"}
+
+	tests := []struct {
+		name    string
+		quote   CoinQuote
+		prev    storedQuote
+		rules   config.ValidationSettings
+		wantErr error
+	}{
+		{"valid", CoinQuote{Price: "100", MarketCap: "10000"}, storedQuote{price: 90}, rules, nil},
+		{"zero price", CoinQuote{Price: "0"}, storedQuote{}, rules, ErrNonPositivePrice},
+		{"negative price", CoinQuote{Price: "-1"}, storedQuote{}, rules, ErrNonPositivePrice},
+		{"NaN", CoinQuote{Price: "NaN"}, storedQuote{}, rules, ErrNonFinite},
+		{"Inf", CoinQuote{Price: "1", Volume24H: "1e400"}, storedQuote{}, rules, ErrNonFinite},
+		{"1000x spike", CoinQuote{Price: "100000"}, storedQuote{price: 100}, rules, ErrPriceMove},
+		{"unconfirmed move", CoinQuote{Price: "300"}, storedQuote{price: 100, rejectedMoves: []float64{300, 290}}, rules, ErrPriceMove},
+		{"no previous price", CoinQuote{Price: "100000"}, storedQuote{}, rules, nil},
+		{"market cap mismatch", CoinQuote{Price: "100", MarketCap: "20000"}, storedQuote{}, rules, ErrMarketCapMismatch},
+		{"zero market cap skipped", CoinQuote{Price: "100", MarketCap: "0"}, storedQuote{}, rules, nil},
+		{"rules disabled", CoinQuote{Price: "0"}, storedQuote{}, config.ValidationSettings{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateQuote(coin, tt.quote, tt.prev, tt.rules)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestValidateQuotes tests that rejected quotes are split out and coins without accepted quotes are invalid
+func TestValidateQuotes(t *testing.T) {
+	rules := config.ValidationSettings{RejectNonPositivePrice: true}
+	data := map[string]CoinInfo{
+		"1": {CmcID: 1, Quote: map[string]CoinQuote{
+			"USD": {Price: "50000"},
+			"EUR": {Price: "0"},
+		}},
+		"1027": {CmcID: 1027, Quote: map[string]CoinQuote{
+			"USD": {Price: "0"},
+		}},
+	}
+
+	accepted, rejected, invalid := validateQuotes(data, nil, rules)
+
+	if quotes := accepted["1"].Quote; len(quotes) != 1 || quotes["USD"].Price != "50000" {
+		t.Errorf("Expected only the USD quote for cmc_id 1, got %+v", quotes)
+	}
+	if _, ok := accepted["1027"]; ok {
+		t.Error("Expected cmc_id 1027 to be rejected")
+	}
+	if len(rejected) != 2 {
+		t.Errorf("Expected 2 rejected quotes, got %d", len(rejected))
+	}
+	if len(invalid) != 1 || invalid[0].CmcID != 1027 || invalid[0].Status != OutcomeInvalid {
+		t.Errorf("Expected cmc_id 1027 invalid, got %+v", invalid)
+	}
+}
+
+// TestValidatePriceMoveRecovery tests that a real large move is accepted after consecutive consistent rejections
+func TestValidatePriceMoveRecovery(t *testing.T) {
+	rules := config.ValidationSettings{MaxPriceMovePct: 50, PriceMoveConfirmations: 2}
+	coin := CoinInfo{CmcID: 1, Symbol: "BTC"}
+	quote := CoinQuote{Price: "310"}
+
+	tests := []struct {
+		name    string
+		moves   []float64
+		wantErr error
+	}{
+		{"first rejection", nil, ErrPriceMove},
+		{"one confirmation", []float64{300}, ErrPriceMove},
+		{"confirmed", []float64{300, 305}, nil},
+		{"confirmed by the newest quotes only", []float64{300, 305, 100000}, nil},
+		{"inconsistent spikes", []float64{300, 100000}, ErrPriceMove},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateQuote(coin, quote, storedQuote{price: 100, rejectedMoves: tt.moves}, rules)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if err := validateQuote(coin, quote, storedQuote{price: 100, rejectedMoves: []float64{300, 305}},
+		config.ValidationSettings{MaxPriceMovePct: 50}); !errors.Is(err, ErrPriceMove) {
+		t.Errorf("Expected ErrPriceMove without confirmations configured, got %v", err)
+	}
+}
+
+// TestLoadStoredQuotesPostgres tests that quarantined price moves since the stored quote are loaded newest first
+func TestLoadStoredQuotesPostgres(t *testing.T) {
+	sqlDB := testPostgres(t)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	coins := testCoins(base, 1)
+	if _, err := writeQuotes(ctx, sqlDB, coins, coinInfoFull); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var rejected []QuoteRejection
+	for i, price := range []Decimal{"300", "305"} {
+		quote := CoinQuote{Price: price, LastUpdated: CMCTime{Time: base.Add(time.Duration(i+1) * time.Minute)}}
+		rejected = append(rejected, QuoteRejection{CmcID: 1, Symbol: "C1", Currency: "USD", Quote: quote, PreviousPrice: 100.5,
+			Err: validateQuote(coins["1"], quote, storedQuote{price: 100.5}, config.ValidationSettings{MaxPriceMovePct: 50})})
+	}
+	// Quarantined before the stored quote, not part of the current run of rejections
+	old := CoinQuote{Price: "1", LastUpdated: CMCTime{Time: base.Add(-time.Minute)}}
+	rejected = append(rejected, QuoteRejection{CmcID: 1, Currency: "USD", Quote: old, Err: ErrPriceMove})
+	if err := quarantineQuotes(ctx, sqlDB, rejected); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, err := loadStoredQuotes(ctx, sqlDB, coins)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	moves := stored[quoteKey{cmcID: 1, currency: "USD"}].rejectedMoves
+	if len(moves) != 2 || moves[0] != 305 || moves[1] != 300 {
+		t.Errorf("Expected rejected moves [305 300], got %v", moves)
+	}
+}
+
+// TestQuarantineQuotesPostgres tests that a rejected price repeated with new fetch time stamps is quarantined once
+// and counts as one price move confirmation
+func TestQuarantineQuotesPostgres(t *testing.T) {
+	sqlDB := testPostgres(t)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	coins := testCoins(base, 1)
+	if _, err := writeQuotes(ctx, sqlDB, coins, coinInfoFull); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i, price := range []Decimal{"300", "300", "305", "300"} {
+		quote := CoinQuote{Price: price, LastUpdated: CMCTime{Time: base.Add(time.Duration(i+1) * time.Minute)}}
+		rejected := []QuoteRejection{{CmcID: 1, Symbol: "C1", Currency: "USD", Quote: quote, PreviousPrice: 100.5,
+			Err: validateQuote(coins["1"], quote, storedQuote{price: 100.5}, config.ValidationSettings{MaxPriceMovePct: 50})}}
+		if err := quarantineQuotes(ctx, sqlDB, rejected); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if n := countRows(t, sqlDB, "coin_quote_quarantine"); n != 2 {
+		t.Errorf("Expected 2 quarantined prices, got %d", n)
+	}
+
+	stored, err := loadStoredQuotes(ctx, sqlDB, coins)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if moves := stored[quoteKey{cmcID: 1, currency: "USD"}].rejectedMoves; len(moves) != 2 {
+		t.Errorf("Expected 2 rejected moves, got %v", moves)
+	}
+}
@@ -0,0 +1,16 @@
+-- Migration: create_tracked_coins_table
+-- Description: Creates the tracked_coins table listing the coins the ticker fetches quotes for
+-- Maps to: coins.TrackedCoin struct
+-- Note: Disable a coin (enabled = false) to stop tracking it without losing the row.
+
+CREATE TABLE IF NOT EXISTS tracked_coins (
+    id SERIAL PRIMARY KEY,
+    cmc_id INT NOT NULL UNIQUE,
+    symbol VARCHAR(20) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for listing enabled coins on every ticker sync
+CREATE INDEX IF NOT EXISTS idx_tracked_coins_enabled ON tracked_coins(enabled);
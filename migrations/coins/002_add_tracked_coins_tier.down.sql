@@ -0,0 +1,4 @@
+-- Migration: add_tracked_coins_tier (rollback)
+-- Description: Drops the tier column of tracked_coins
+
+ALTER TABLE tracked_coins DROP COLUMN IF EXISTS tier;
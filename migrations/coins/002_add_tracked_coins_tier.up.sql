@@ -0,0 +1,6 @@
+-- Migration: add_tracked_coins_tier
+-- Description: Adds the polling tier of each tracked coin (ex. hot, normal, cold)
+-- Maps to: coins.TrackedCoin.Tier
+-- Note: Tier intervals are configured in TICKER_TIERS. Coins with an unknown tier are polled with TICKER_DEFAULT_TIER.
+
+ALTER TABLE tracked_coins ADD COLUMN IF NOT EXISTS tier VARCHAR(20) NOT NULL DEFAULT 'normal';
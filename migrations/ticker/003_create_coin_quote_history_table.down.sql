@@ -0,0 +1,5 @@
+-- Migration: create_coin_quote_history_table (rollback)
+-- Description: Drops the coin_quote_history table and its indexes
+
+DROP INDEX IF EXISTS idx_coin_quote_history_coin_id_last_updated;
+DROP TABLE IF EXISTS coin_quote_history;
@@ -0,0 +1,24 @@
+-- Migration: create_coin_quote_history_table
+-- Description: Creates the coin_quote_history table to store a time series of quotes from Coinmarketcap API
+-- Maps to: ticker.CoinQuote struct
+-- Note: One row per coin per upstream last_updated. coin_quote keeps the latest row only.
+-- Re-syncing the same upstream timestamp is a no-op (ON CONFLICT DO NOTHING on unique_coin_quote_history).
+
+CREATE TABLE IF NOT EXISTS coin_quote_history (
+    id BIGSERIAL PRIMARY KEY,
+    coin_id INT NOT NULL REFERENCES coin_info(id) ON DELETE CASCADE,
+    price NUMERIC(20, 8) NOT NULL,
+    market_cap NUMERIC(20, 2),
+    fully_diluted_market_cap NUMERIC(20, 2),
+    volume_24h NUMERIC(20, 2),
+    percent_change_1h NUMERIC(10, 4),
+    percent_change_24h NUMERIC(10, 4),
+    percent_change_7d NUMERIC(10, 4),
+    last_updated TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- One quote per coin per upstream timestamp
+    CONSTRAINT unique_coin_quote_history UNIQUE(coin_id, last_updated)
+);
+
+-- Index for time range queries per coin (charts, backtests)
+CREATE INDEX IF NOT EXISTS idx_coin_quote_history_coin_id_last_updated ON coin_quote_history(coin_id, last_updated DESC);
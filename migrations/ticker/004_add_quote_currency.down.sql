@@ -0,0 +1,14 @@
+-- Migration: add_quote_currency (rollback)
+-- Description: Restores one USD quote per coin. Non-USD quotes are deleted.
+
+DROP INDEX IF EXISTS idx_coin_quote_history_coin_id_currency_last_updated;
+DELETE FROM coin_quote_history WHERE currency <> 'USD';
+ALTER TABLE coin_quote_history DROP CONSTRAINT IF EXISTS unique_coin_quote_history;
+ALTER TABLE coin_quote_history DROP COLUMN IF EXISTS currency;
+ALTER TABLE coin_quote_history ADD CONSTRAINT unique_coin_quote_history UNIQUE(coin_id, last_updated);
+CREATE INDEX IF NOT EXISTS idx_coin_quote_history_coin_id_last_updated ON coin_quote_history(coin_id, last_updated DESC);
+
+DELETE FROM coin_quote WHERE currency <> 'USD';
+ALTER TABLE coin_quote DROP CONSTRAINT IF EXISTS unique_coin_quote;
+ALTER TABLE coin_quote DROP COLUMN IF EXISTS currency;
+ALTER TABLE coin_quote ADD CONSTRAINT unique_coin_quote UNIQUE(coin_id);
@@ -0,0 +1,19 @@
+-- Migration: add_quote_currency
+-- Description: Keys coin_quote and coin_quote_history by (coin, currency) to store one quote per convert target
+-- Maps to: ticker.CoinInfo.Quote map key (convert symbol "USD" or convert_id "2781")
+-- Note: Existing rows were fetched with convert=USD and are backfilled as USD.
+
+ALTER TABLE coin_quote ADD COLUMN IF NOT EXISTS currency VARCHAR(20) NOT NULL DEFAULT 'USD';
+ALTER TABLE coin_quote ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE coin_quote DROP CONSTRAINT IF EXISTS unique_coin_quote;
+-- One quote per coin per currency (most recent)
+ALTER TABLE coin_quote ADD CONSTRAINT unique_coin_quote UNIQUE(coin_id, currency);
+
+ALTER TABLE coin_quote_history ADD COLUMN IF NOT EXISTS currency VARCHAR(20) NOT NULL DEFAULT 'USD';
+ALTER TABLE coin_quote_history ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE coin_quote_history DROP CONSTRAINT IF EXISTS unique_coin_quote_history;
+-- One quote per coin per currency per upstream timestamp
+ALTER TABLE coin_quote_history ADD CONSTRAINT unique_coin_quote_history UNIQUE(coin_id, currency, last_updated);
+
+DROP INDEX IF EXISTS idx_coin_quote_history_coin_id_last_updated;
+CREATE INDEX IF NOT EXISTS idx_coin_quote_history_coin_id_currency_last_updated ON coin_quote_history(coin_id, currency, last_updated DESC);
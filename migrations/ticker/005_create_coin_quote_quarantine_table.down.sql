@@ -0,0 +1,6 @@
+-- Migration: create_coin_quote_quarantine_table (rollback)
+-- Description: Drops the coin_quote_quarantine table and its indexes
+
+DROP INDEX IF EXISTS idx_coin_quote_quarantine_created_at;
+DROP INDEX IF EXISTS idx_coin_quote_quarantine_cmc_id;
+DROP TABLE IF EXISTS coin_quote_quarantine;
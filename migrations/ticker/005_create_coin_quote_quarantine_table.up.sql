@@ -0,0 +1,24 @@
+-- Migration: create_coin_quote_quarantine_table
+-- Description: Creates the coin_quote_quarantine table to store quotes rejected by ticker validation
+-- Maps to: ticker.QuoteRejection struct
+-- Note: Rejected quotes never overwrite coin_quote. The raw quote is kept in payload for review.
+-- The same rejected upstream quote is only stored once (unique_coin_quote_quarantine). The ticker also skips a
+-- rejected price already quarantined since the last stored quote (providers stamping quotes with the fetch time).
+
+CREATE TABLE IF NOT EXISTS coin_quote_quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    cmc_id INT NOT NULL,
+    symbol VARCHAR(20),
+    currency VARCHAR(20) NOT NULL,
+    price NUMERIC(20, 8),
+    previous_price NUMERIC(20, 8),
+    reason TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    last_updated TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_coin_quote_quarantine UNIQUE(cmc_id, currency, last_updated)
+);
+
+-- Indexes for reviewing recent rejections per coin
+CREATE INDEX IF NOT EXISTS idx_coin_quote_quarantine_cmc_id ON coin_quote_quarantine(cmc_id);
+CREATE INDEX IF NOT EXISTS idx_coin_quote_quarantine_created_at ON coin_quote_quarantine(created_at DESC);
@@ -0,0 +1,5 @@
+-- Migration: create_coin_candle_table (rollback)
+-- Description: Drops the coin_candle table and its indexes
+
+DROP INDEX IF EXISTS idx_coin_candle_lookup;
+DROP TABLE IF EXISTS coin_candle;
@@ -0,0 +1,29 @@
+-- Migration: create_coin_candle_table
+-- Description: Creates the coin_candle table to store OHLCV candles aggregated from ticker quote snapshots
+-- Maps to: ticker.Candle struct
+-- Note: Candles are updated incrementally on every sync and can be rebuilt from coin_quote_history.
+-- volume_24h_at_close is the upstream rolling volume_24h at the close of the candle, not the volume traded during
+-- the interval (snapshots do not carry per-trade volume), so 5m and 1h candles show 24h volume.
+
+CREATE TABLE IF NOT EXISTS coin_candle (
+    id BIGSERIAL PRIMARY KEY,
+    coin_id INT NOT NULL REFERENCES coin_info(id) ON DELETE CASCADE,
+    currency VARCHAR(20) NOT NULL,
+    resolution VARCHAR(4) NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    open NUMERIC(20, 8) NOT NULL,
+    high NUMERIC(20, 8) NOT NULL,
+    low NUMERIC(20, 8) NOT NULL,
+    close NUMERIC(20, 8) NOT NULL,
+    volume_24h_at_close NUMERIC(20, 2),
+    open_time TIMESTAMP NOT NULL,  -- last_updated of the first snapshot in the bucket
+    close_time TIMESTAMP NOT NULL, -- last_updated of the last snapshot in the bucket
+    sample_count INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- One candle per coin per currency per resolution per bucket
+    CONSTRAINT unique_coin_candle UNIQUE(coin_id, currency, resolution, bucket_start)
+);
+
+-- Index for chart queries (coin, currency, resolution over a time range)
+CREATE INDEX IF NOT EXISTS idx_coin_candle_lookup ON coin_candle(coin_id, currency, resolution, bucket_start DESC);
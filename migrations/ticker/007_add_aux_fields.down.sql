@@ -0,0 +1,30 @@
+-- Migration: add_aux_fields (rollback)
+-- Description: Drops the CMC aux and extended quote columns
+
+DROP INDEX IF EXISTS idx_coin_info_cmc_rank;
+
+ALTER TABLE coin_quote_history
+    DROP COLUMN IF EXISTS tvl,
+    DROP COLUMN IF EXISTS percent_change_90d,
+    DROP COLUMN IF EXISTS percent_change_60d,
+    DROP COLUMN IF EXISTS percent_change_30d,
+    DROP COLUMN IF EXISTS volume_change_24h,
+    DROP COLUMN IF EXISTS volume_24h_reported;
+
+ALTER TABLE coin_quote
+    DROP COLUMN IF EXISTS tvl,
+    DROP COLUMN IF EXISTS percent_change_90d,
+    DROP COLUMN IF EXISTS percent_change_60d,
+    DROP COLUMN IF EXISTS percent_change_30d,
+    DROP COLUMN IF EXISTS volume_change_24h,
+    DROP COLUMN IF EXISTS volume_24h_reported;
+
+ALTER TABLE coin_info
+    DROP COLUMN IF EXISTS tvl_ratio,
+    DROP COLUMN IF EXISTS self_reported_market_cap,
+    DROP COLUMN IF EXISTS self_reported_circulating_supply,
+    DROP COLUMN IF EXISTS infinite_supply,
+    DROP COLUMN IF EXISTS max_supply,
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS num_market_pairs,
+    DROP COLUMN IF EXISTS cmc_rank;
@@ -0,0 +1,33 @@
+-- Migration: add_aux_fields
+-- Description: Adds the CMC aux and extended quote fields promoted into ticker.CoinInfo and ticker.CoinQuote
+-- Maps to: ticker.CoinInfo and ticker.CoinQuote structs
+-- Note: Aux fields are only returned when requested in CMC_AUX and are stored as NULL otherwise.
+
+ALTER TABLE coin_info
+    ADD COLUMN IF NOT EXISTS cmc_rank INT,
+    ADD COLUMN IF NOT EXISTS num_market_pairs INT,
+    ADD COLUMN IF NOT EXISTS tags TEXT[],
+    ADD COLUMN IF NOT EXISTS max_supply NUMERIC(20, 8),
+    ADD COLUMN IF NOT EXISTS infinite_supply BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS self_reported_circulating_supply NUMERIC(20, 8),
+    ADD COLUMN IF NOT EXISTS self_reported_market_cap NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS tvl_ratio NUMERIC(20, 8);
+
+ALTER TABLE coin_quote
+    ADD COLUMN IF NOT EXISTS volume_24h_reported NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS volume_change_24h NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS percent_change_30d NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS percent_change_60d NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS percent_change_90d NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS tvl NUMERIC(20, 2);
+
+ALTER TABLE coin_quote_history
+    ADD COLUMN IF NOT EXISTS volume_24h_reported NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS volume_change_24h NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS percent_change_30d NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS percent_change_60d NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS percent_change_90d NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS tvl NUMERIC(20, 2);
+
+-- Index for ranking queries (top N by cmc_rank)
+CREATE INDEX IF NOT EXISTS idx_coin_info_cmc_rank ON coin_info(cmc_rank);
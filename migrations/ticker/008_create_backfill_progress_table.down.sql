@@ -0,0 +1,5 @@
+-- Migration: create_backfill_progress_table (rollback)
+-- Description: Drops the backfill_progress table and its indexes
+
+DROP INDEX IF EXISTS idx_backfill_progress_status;
+DROP TABLE IF EXISTS backfill_progress;
@@ -0,0 +1,25 @@
+-- Migration: create_backfill_progress_table
+-- Description: Creates the backfill_progress table to track historical quote backfills from Coinmarketcap API
+-- Maps to: ticker.BackfillRequest and ticker.BackfillReport structs
+-- Note: cursor is the timestamp of the last point written to coin_quote_history. An interrupted backfill resumes
+-- after cursor. status is one of running, done, capped (credit cap reached) or failed.
+
+CREATE TABLE IF NOT EXISTS backfill_progress (
+    id SERIAL PRIMARY KEY,
+    cmc_id INT NOT NULL,
+    currency VARCHAR(20) NOT NULL,
+    interval VARCHAR(10) NOT NULL,
+    time_start TIMESTAMP NOT NULL,
+    time_end TIMESTAMP NOT NULL,
+    cursor TIMESTAMP,
+    status VARCHAR(10) NOT NULL DEFAULT 'running',
+    points_written INT NOT NULL DEFAULT 0,
+    credits_used INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- One backfill per coin, currency, interval and range
+    CONSTRAINT unique_backfill_progress UNIQUE(cmc_id, currency, interval, time_start, time_end)
+);
+
+CREATE INDEX IF NOT EXISTS idx_backfill_progress_status ON backfill_progress(status);
@@ -0,0 +1,5 @@
+-- Migration: create_coin_quote_gap_table (rollback)
+-- Description: Drops the coin_quote_gap table and its indexes
+
+DROP INDEX IF EXISTS idx_coin_quote_gap_status;
+DROP TABLE IF EXISTS coin_quote_gap;
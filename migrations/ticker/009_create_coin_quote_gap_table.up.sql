@@ -0,0 +1,24 @@
+-- Migration: create_coin_quote_gap_table
+-- Description: Creates the coin_quote_gap table to record gaps found in coin_quote_history and their repair
+-- Maps to: ticker.QuoteGap struct
+-- Note: gap_start is the last history point before the gap and identifies the gap. gap_end is the next point, gaps
+-- still open at scan time are recorded once they close. status is filled or unfilled. Unfilled gaps are retried
+-- on later scans until attempts reaches the retry limit.
+
+CREATE TABLE IF NOT EXISTS coin_quote_gap (
+    id BIGSERIAL PRIMARY KEY,
+    cmc_id INT NOT NULL,
+    currency VARCHAR(20) NOT NULL,
+    gap_start TIMESTAMP NOT NULL,
+    gap_end TIMESTAMP NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'unfilled',
+    points_filled INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_coin_quote_gap UNIQUE(cmc_id, currency, gap_start)
+);
+
+-- Index for reviewing unfilled gaps
+CREATE INDEX IF NOT EXISTS idx_coin_quote_gap_status ON coin_quote_gap(status);
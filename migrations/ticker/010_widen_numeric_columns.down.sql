@@ -0,0 +1,52 @@
+-- Migration: widen_numeric_columns (rollback)
+-- Description: Restores the previous NUMERIC column types
+-- Note: Fails if stored values do not fit the narrower types. Extra price digits are rounded.
+
+ALTER TABLE coin_candle
+    ALTER COLUMN open TYPE NUMERIC(20, 8),
+    ALTER COLUMN high TYPE NUMERIC(20, 8),
+    ALTER COLUMN low TYPE NUMERIC(20, 8),
+    ALTER COLUMN close TYPE NUMERIC(20, 8),
+    ALTER COLUMN volume_24h_at_close TYPE NUMERIC(20, 2);
+
+ALTER TABLE coin_quote_quarantine
+    ALTER COLUMN price TYPE NUMERIC(20, 8),
+    ALTER COLUMN previous_price TYPE NUMERIC(20, 8);
+
+ALTER TABLE coin_quote_history
+    ALTER COLUMN price TYPE NUMERIC(20, 8),
+    ALTER COLUMN market_cap TYPE NUMERIC(20, 2),
+    ALTER COLUMN fully_diluted_market_cap TYPE NUMERIC(20, 2),
+    ALTER COLUMN volume_24h TYPE NUMERIC(20, 2),
+    ALTER COLUMN volume_24h_reported TYPE NUMERIC(20, 2),
+    ALTER COLUMN volume_change_24h TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_1h TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_24h TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_7d TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_30d TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_60d TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_90d TYPE NUMERIC(10, 4),
+    ALTER COLUMN tvl TYPE NUMERIC(20, 2);
+
+ALTER TABLE coin_quote
+    ALTER COLUMN price TYPE NUMERIC(20, 8),
+    ALTER COLUMN market_cap TYPE NUMERIC(20, 2),
+    ALTER COLUMN fully_diluted_market_cap TYPE NUMERIC(20, 2),
+    ALTER COLUMN volume_24h TYPE NUMERIC(20, 2),
+    ALTER COLUMN volume_24h_reported TYPE NUMERIC(20, 2),
+    ALTER COLUMN volume_change_24h TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_1h TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_24h TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_7d TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_30d TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_60d TYPE NUMERIC(10, 4),
+    ALTER COLUMN percent_change_90d TYPE NUMERIC(10, 4),
+    ALTER COLUMN tvl TYPE NUMERIC(20, 2);
+
+ALTER TABLE coin_info
+    ALTER COLUMN circulating_supply TYPE NUMERIC(20, 8),
+    ALTER COLUMN total_supply TYPE NUMERIC(20, 8),
+    ALTER COLUMN max_supply TYPE NUMERIC(20, 8),
+    ALTER COLUMN self_reported_circulating_supply TYPE NUMERIC(20, 8),
+    ALTER COLUMN self_reported_market_cap TYPE NUMERIC(20, 2),
+    ALTER COLUMN tvl_ratio TYPE NUMERIC(20, 8);
@@ -0,0 +1,54 @@
+-- Migration: widen_numeric_columns
+-- Description: Widens NUMERIC columns so sub-cent prices keep their digits and meme coin supplies do not overflow
+-- Maps to: ticker.Decimal values (see internal/ticker/decimal.go for the column types checked before writing)
+-- Note: prices NUMERIC(40, 18), supplies, market caps, volumes and TVL NUMERIC(40, 8), percent changes and ratios
+-- NUMERIC(30, 8). Widening never loses data. Values that still do not fit are stored as NULL and reported by the ticker.
+
+ALTER TABLE coin_info
+    ALTER COLUMN circulating_supply TYPE NUMERIC(40, 8),
+    ALTER COLUMN total_supply TYPE NUMERIC(40, 8),
+    ALTER COLUMN max_supply TYPE NUMERIC(40, 8),
+    ALTER COLUMN self_reported_circulating_supply TYPE NUMERIC(40, 8),
+    ALTER COLUMN self_reported_market_cap TYPE NUMERIC(40, 8),
+    ALTER COLUMN tvl_ratio TYPE NUMERIC(30, 8);
+
+ALTER TABLE coin_quote
+    ALTER COLUMN price TYPE NUMERIC(40, 18),
+    ALTER COLUMN market_cap TYPE NUMERIC(40, 8),
+    ALTER COLUMN fully_diluted_market_cap TYPE NUMERIC(40, 8),
+    ALTER COLUMN volume_24h TYPE NUMERIC(40, 8),
+    ALTER COLUMN volume_24h_reported TYPE NUMERIC(40, 8),
+    ALTER COLUMN volume_change_24h TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_1h TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_24h TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_7d TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_30d TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_60d TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_90d TYPE NUMERIC(30, 8),
+    ALTER COLUMN tvl TYPE NUMERIC(40, 8);
+
+ALTER TABLE coin_quote_history
+    ALTER COLUMN price TYPE NUMERIC(40, 18),
+    ALTER COLUMN market_cap TYPE NUMERIC(40, 8),
+    ALTER COLUMN fully_diluted_market_cap TYPE NUMERIC(40, 8),
+    ALTER COLUMN volume_24h TYPE NUMERIC(40, 8),
+    ALTER COLUMN volume_24h_reported TYPE NUMERIC(40, 8),
+    ALTER COLUMN volume_change_24h TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_1h TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_24h TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_7d TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_30d TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_60d TYPE NUMERIC(30, 8),
+    ALTER COLUMN percent_change_90d TYPE NUMERIC(30, 8),
+    ALTER COLUMN tvl TYPE NUMERIC(40, 8);
+
+ALTER TABLE coin_quote_quarantine
+    ALTER COLUMN price TYPE NUMERIC(40, 18),
+    ALTER COLUMN previous_price TYPE NUMERIC(40, 18);
+
+ALTER TABLE coin_candle
+    ALTER COLUMN open TYPE NUMERIC(40, 18),
+    ALTER COLUMN high TYPE NUMERIC(40, 18),
+    ALTER COLUMN low TYPE NUMERIC(40, 18),
+    ALTER COLUMN close TYPE NUMERIC(40, 18),
+    ALTER COLUMN volume_24h_at_close TYPE NUMERIC(40, 8);
@@ -0,0 +1,5 @@
+-- Migration: create_global_metrics_table (rollback)
+-- Description: Drops the global_metrics table and its indexes
+
+DROP INDEX IF EXISTS idx_global_metrics_currency_last_updated;
+DROP TABLE IF EXISTS global_metrics;
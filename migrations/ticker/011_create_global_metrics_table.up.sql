@@ -0,0 +1,34 @@
+-- Migration: create_global_metrics_table
+-- Description: Creates the global_metrics table to store a time series of global market metrics from Coinmarketcap API
+-- Maps to: ticker.GlobalMetrics and ticker.GlobalQuote structs
+-- Note: One row per currency per upstream last_updated. Re-syncing the same upstream timestamp is a no-op
+-- (ON CONFLICT DO NOTHING on unique_global_metrics). DeFi, stablecoin and derivatives totals are reported in USD by CMC.
+
+CREATE TABLE IF NOT EXISTS global_metrics (
+    id BIGSERIAL PRIMARY KEY,
+    currency VARCHAR(20) NOT NULL,
+    total_market_cap NUMERIC(40, 8),
+    total_volume_24h NUMERIC(40, 8),
+    total_volume_24h_reported NUMERIC(40, 8),
+    altcoin_market_cap NUMERIC(40, 8),
+    altcoin_volume_24h NUMERIC(40, 8),
+    total_market_cap_change_24h NUMERIC(30, 8),
+    total_volume_24h_change_24h NUMERIC(30, 8),
+    btc_dominance NUMERIC(30, 8),
+    eth_dominance NUMERIC(30, 8),
+    defi_market_cap NUMERIC(40, 8),
+    defi_volume_24h NUMERIC(40, 8),
+    stablecoin_market_cap NUMERIC(40, 8),
+    stablecoin_volume_24h NUMERIC(40, 8),
+    derivatives_volume_24h NUMERIC(40, 8),
+    active_cryptocurrencies INT,
+    active_exchanges INT,
+    active_market_pairs INT,
+    last_updated TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- One row per currency per upstream timestamp
+    CONSTRAINT unique_global_metrics UNIQUE(currency, last_updated)
+);
+
+-- Index for time range queries (dashboard charts)
+CREATE INDEX IF NOT EXISTS idx_global_metrics_currency_last_updated ON global_metrics(currency, last_updated DESC);